@@ -2,28 +2,36 @@ package main
 
 import (
 	"context"
-	"embed"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"dynamic-route-53-dns/internal/api"
+	"dynamic-route-53-dns/internal/api/middleware"
 	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/metrics"
+	"dynamic-route-53-dns/internal/notify"
 	"dynamic-route-53-dns/internal/route53"
+	"dynamic-route-53-dns/internal/secrets"
+	"dynamic-route-53-dns/internal/tracing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	fiberadapter "github.com/awslabs/aws-lambda-go-api-proxy/fiber"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
-//go:embed templates
-var templatesFS embed.FS
-
 var fiberLambda *fiberadapter.FiberLambda
 
+// logFlushTimeout bounds how long an invocation waits for queued request logs
+// and notifications
+const logFlushTimeout = 2 * time.Second
+
 func initAWS() {
 	// Initialize database
 	if err := database.Init(context.Background()); err != nil {
@@ -34,6 +42,11 @@ func initAWS() {
 	if err := route53.Init(context.Background()); err != nil {
 		log.Fatalf("Failed to initialize Route 53 client: %v", err)
 	}
+
+	// Load credentials from Secrets Manager when ADMIN_SECRET_ARN is set
+	if err := secrets.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
 }
 
 func init() {
@@ -48,40 +61,59 @@ func init() {
 }
 
 func createApp() *fiber.App {
-	// Get templates subdirectory
-	templatesSubFS, err := fs.Sub(templatesFS, "templates")
+	app, err := api.NewApp()
 	if err != nil {
-		log.Fatalf("Failed to get templates subdirectory: %v", err)
+		log.Fatalf("Failed to create app: %v", err)
 	}
-
-	// Configure Fiber with embedded templates
-	engine := NewHTMLEngine(templatesSubFS)
-
-	app := fiber.New(fiber.Config{
-		Views:                   engine,
-		DisableStartupMessage:   true,
-		EnableTrustedProxyCheck: true,
-		TrustedProxies:          []string{"*"},
-		ProxyHeader:             "X-Forwarded-For",
-	})
-
-	// Recovery middleware
-	app.Use(recover.New())
-
-	// Setup routes
-	api.SetupRoutes(app)
-
 	return app
 }
 
 // Handler is the Lambda handler function for HTTP API v2
 func Handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	return fiberLambda.ProxyWithContextV2(ctx, req)
+	// Hand the invocation's trace context to the app; never trust a client-sent one
+	if req.Headers != nil {
+		delete(req.Headers, strings.ToLower(tracing.TraceHeader))
+		delete(req.Headers, strings.ToLower(middleware.DeadlineHeader))
+	}
+	if traceID := tracing.LambdaTraceID(ctx); traceID != "" && tracing.Enabled() {
+		if req.Headers == nil {
+			req.Headers = make(map[string]string)
+		}
+		req.Headers[strings.ToLower(tracing.TraceHeader)] = traceID
+	}
+
+	// Likewise pass on the invocation deadline so requests can fail fast
+	if deadline, ok := ctx.Deadline(); ok {
+		if req.Headers == nil {
+			req.Headers = make(map[string]string)
+		}
+		req.Headers[strings.ToLower(middleware.DeadlineHeader)] = strconv.FormatInt(deadline.UnixMilli(), 10)
+	}
+	resp, err := fiberLambda.ProxyWithContextV2(ctx, req)
+
+	// The environment may be frozen once we return, so push counters,
+	// deliver queued request logs and finish chat and webhook notifications now
+	metrics.Push(ctx)
+	flushCtx, cancel := context.WithTimeout(ctx, logFlushTimeout)
+	middleware.FlushLogs(flushCtx)
+	notify.Wait(flushCtx)
+	cancel()
+	return resp, err
 }
 
 func main() {
 	// Check if running in Lambda
 	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		// Lambda sends SIGTERM before reclaiming the environment when an
+		// extension is registered; drop this environment's metrics group
+		// then. Without one, stale groups are pruned by later pushes.
+		go func() {
+			quit := make(chan os.Signal, 1)
+			signal.Notify(quit, syscall.SIGTERM)
+			<-quit
+			metrics.Delete(context.Background())
+			os.Exit(0)
+		}()
 		lambda.Start(Handler)
 	} else {
 		// Local development mode - initialize AWS clients
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"dynamic-route-53-dns/internal/api"
+	"dynamic-route-53-dns/internal/api/middleware"
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/notify"
+	"dynamic-route-53-dns/internal/route53"
+	"dynamic-route-53-dns/internal/secrets"
+)
+
+// defaultShutdownTimeout bounds how long in-flight requests may take to drain
+const defaultShutdownTimeout = 30 * time.Second
+
+func initAWS() {
+	// Initialize database
+	if err := database.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	// Initialize Route 53 client
+	if err := route53.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to initialize Route 53 client: %v", err)
+	}
+
+	// Load credentials from Secrets Manager when ADMIN_SECRET_ARN is set
+	if err := secrets.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+}
+
+// shutdownTimeout returns SHUTDOWN_TIMEOUT (e.g. "45s") or the default
+func shutdownTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultShutdownTimeout
+}
+
+func main() {
+	initAWS()
+
+	app, err := api.NewApp()
+	if err != nil {
+		log.Fatalf("Failed to create app: %v", err)
+	}
+
+	addr := ":" + os.Getenv("PORT")
+	if addr == ":" {
+		addr = ":3000"
+	}
+
+	// Serve until the listener is closed by shutdown
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", addr)
+		serverErr <- app.Listen(addr)
+	}()
+
+	// Wait for a termination signal (SIGTERM from the container runtime)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	case sig := <-quit:
+		log.Printf("Received %s, draining in-flight requests", sig)
+		if err := app.ShutdownWithTimeout(shutdownTimeout()); err != nil {
+			log.Fatalf("Failed to shut down cleanly: %v", err)
+		}
+
+		// Deliver request logs still queued for an HTTP log sink and
+		// notifications still being posted
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		middleware.FlushLogs(ctx)
+		notify.Wait(ctx)
+		cancel()
+
+		log.Println("Server stopped")
+	}
+}
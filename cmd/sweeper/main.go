@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/notify"
+	"dynamic-route-53-dns/internal/route53"
+	"dynamic-route-53-dns/internal/secrets"
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// notifyTimeout bounds how long a run waits for notifications to be sent
+const notifyTimeout = 5 * time.Second
+
+// taskPublishPending selects publishing deferred addresses instead of the sweep
+const taskPublishPending = "publish-pending"
+
+// Event is the scheduled event's input. An empty Task runs the sweep.
+type Event struct {
+	Task string `json:"task"`
+}
+
+// Handler deletes expired sessions, rate-limit entries, logs and other items
+// carrying a ttl attribute. It is run on a schedule for tables without
+// DynamoDB TTL enabled. With the publish-pending task it instead publishes
+// addresses held back by the change cooldown once it has ended.
+func Handler(ctx context.Context, event Event) error {
+	if event.Task == taskPublishPending {
+		published, err := service.NewUpdateService().PublishPending(ctx, time.Now().UTC())
+		log.Printf("Published %d pending addresses", published)
+
+		// Let the IP change notifications finish before Lambda freezes
+		waitCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		notify.Wait(waitCtx)
+		cancel()
+		return err
+	}
+
+	deleted, err := database.SweepExpired(ctx, time.Now().UTC())
+	log.Printf("Swept %d expired items", deleted)
+	return err
+}
+
+func main() {
+	if err := database.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := route53.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to initialize Route 53 client: %v", err)
+	}
+	if err := secrets.Init(context.Background()); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+
+	// Check if running in Lambda
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		lambda.Start(Handler)
+		return
+	}
+
+	// Local mode - run a single sweep, or the task named on the command line
+	var event Event
+	if len(os.Args) > 1 {
+		event.Task = os.Args[1]
+	}
+	if err := Handler(context.Background(), event); err != nil {
+		log.Fatalf("Sweep failed: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dynamic-route-53-dns/internal/api/middleware"
+	"dynamic-route-53-dns/internal/tracing"
+	"dynamic-route-53-dns/internal/web"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// Request limits used when the environment does not override them
+const (
+	DefaultBodyLimit       = 1 << 20 // 1 MiB, BODY_LIMIT
+	DefaultImportBodyLimit = 5 << 20 // 5 MiB, IMPORT_BODY_LIMIT; also the hard cap for all requests
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 30 * time.Second
+)
+
+// NewApp creates the Fiber app with templates, middleware, and routes.
+// Both the Lambda and server entrypoints use it so they stay consistent.
+func NewApp() (*fiber.App, error) {
+	// Configure Fiber with embedded templates
+	engine, err := web.NewEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	app := fiber.New(fiber.Config{
+		Views:                   engine,
+		ErrorHandler:            errorHandler,
+		BodyLimit:               envInt("IMPORT_BODY_LIMIT", DefaultImportBodyLimit),
+		ReadTimeout:             envDuration("READ_TIMEOUT", DefaultReadTimeout),
+		WriteTimeout:            envDuration("WRITE_TIMEOUT", DefaultWriteTimeout),
+		DisableStartupMessage:   true,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies(),
+		ProxyHeader:             "X-Forwarded-For",
+	})
+
+	// Recovery middleware
+	app.Use(recover.New())
+
+	// Tag each request with an ID for log and error correlation
+	app.Use(requestid.New())
+
+	// Join the Lambda invocation's X-Ray trace when XRAY_ENABLED is set
+	app.Use(tracing.Middleware())
+
+	// Fail downstream calls before the Lambda invocation times out
+	app.Use(middleware.LambdaDeadline())
+
+	// Ordinary requests get a tighter body limit than bulk imports
+	app.Use(middleware.BodyLimit(envInt("BODY_LIMIT", DefaultBodyLimit)))
+
+	// Setup routes
+	SetupRoutes(app)
+
+	return app, nil
+}
+
+// trustedProxies returns the proxies from TRUSTED_PROXIES whose forwarding
+// headers Fiber may honour. None are trusted by default, so a client cannot
+// spoof its address with X-Forwarded-For.
+func trustedProxies() []string {
+	var proxies []string
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, entry)
+		}
+	}
+	return proxies
+}
+
+// envInt reads a positive integer from the environment, or returns fallback
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// envDuration reads a positive duration such as "30s" from the environment, or returns fallback
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorHandler renders errors that reach Fiber as a styled page for browsers
+// or as JSON for API clients, including the request ID for correlation
+func errorHandler(c *fiber.Ctx, err error) error {
+	code, message := classifyError(err)
+	requestID, _ := c.Locals("requestid").(string)
+
+	if code >= fiber.StatusInternalServerError {
+		fmt.Printf("Error: %s %s (request %s): %v\n", c.Method(), c.Path(), requestID, err)
+	}
+
+	c.Status(code)
+	if strings.Contains(c.Get(fiber.HeaderAccept), fiber.MIMEApplicationJSON) {
+		return c.JSON(fiber.Map{
+			"error":      message,
+			"status":     code,
+			"request_id": requestID,
+		})
+	}
+
+	renderErr := c.Render("errors/error", fiber.Map{
+		"PageTitle":  fmt.Sprintf("Error %d - Dynamic DNS", code),
+		"IsLoggedIn": c.Locals("username") != nil,
+		"Username":   c.Locals("username"),
+		"CSRFToken":  c.Locals("csrf_token"),
+		"Status":     code,
+		"Message":    message,
+		"RequestID":  requestID,
+	})
+	if renderErr != nil {
+		return c.SendString(message)
+	}
+	return nil
+}
+
+// classifyError maps an error to an HTTP status and a message safe to show.
+// Unexpected errors are reported generically so internals are not leaked.
+func classifyError(err error) (int, string) {
+	var fiberErr *fiber.Error
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &fiberErr):
+		return fiberErr.Code, fiberErr.Message
+	case errors.As(err, &validationErr):
+		return fiber.StatusBadRequest, validationErr.Message
+	case errors.Is(err, service.ErrRecordNotFound), errors.Is(err, service.ErrZoneNotFound):
+		return fiber.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrRecordExists):
+		return fiber.StatusConflict, err.Error()
+	default:
+		return fiber.StatusInternalServerError, "Something went wrong while handling the request"
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"dynamic-route-53-dns/internal/api/middleware"
 	"dynamic-route-53-dns/internal/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,10 +22,10 @@ func NewAuthHandler() *AuthHandler {
 // LoginPage renders the login page
 func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 	// Check if already logged in
-	sessionID := c.Cookies("session_id")
+	sessionID := middleware.GetSessionFromCookie(c)
 	if sessionID != "" {
-		if _, valid := h.authService.ValidateSession(c.Context(), sessionID); valid {
-			return c.Redirect("/zones")
+		if _, valid := h.authService.ValidateSession(c.UserContext(), sessionID); valid {
+			return c.Redirect("/")
 		}
 	}
 
@@ -40,49 +41,67 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	username := c.FormValue("username")
 	password := c.FormValue("password")
 
-	result := h.authService.Login(c.Context(), username, password)
+	clientIP, _ := getSourceIP(c)
+
+	req := &service.LoginRequest{
+		Username:  username,
+		Password:  password,
+		ClientIP:  clientIP,
+		UserAgent: c.Get("User-Agent"),
+	}
+	if field := h.authService.ChallengeField(); field != "" {
+		req.ChallengeResponse = c.FormValue(field)
+	}
+
+	result := h.authService.Login(c.UserContext(), req)
 
 	if !result.Success {
 		return c.Render("auth/login", fiber.Map{
-			"PageTitle":   "Login - Dynamic DNS",
-			"CurrentPath": "/login",
-			"CSRFToken":   c.Locals("csrf_token"),
-			"FlashError":  result.Error,
-			"Username":    username,
+			"PageTitle":         "Login - Dynamic DNS",
+			"CurrentPath":       "/login",
+			"CSRFToken":         c.Locals("csrf_token"),
+			"FlashError":        result.Error,
+			"Username":          username,
+			"AttemptsRemaining": result.AttemptsRemaining,
+			"Challenge":         result.Challenge,
 		})
 	}
 
 	// Set session cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "session_id",
-		Value:    result.SessionID,
-		Path:     "/",
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: "Strict",
-		MaxAge:   86400, // 24 hours
-	})
+	middleware.SetSessionCookie(c, result.SessionID)
 
-	return c.Redirect("/zones")
+	return c.Redirect("/")
+}
+
+// LoginHistory renders the recent successful logins for the current user
+func (h *AuthHandler) LoginHistory(c *fiber.Ctx) error {
+	username, _ := c.Locals("username").(string)
+
+	logins, err := h.authService.LoginHistory(c.UserContext(), username, 10)
+	data := fiber.Map{
+		"PageTitle":   "Login History - Dynamic DNS",
+		"CurrentPath": "/logins",
+		"IsLoggedIn":  true,
+		"Username":    username,
+		"CSRFToken":   c.Locals("csrf_token"),
+		"Logins":      logins,
+	}
+	if err != nil {
+		data["FlashError"] = "Failed to load login history: " + err.Error()
+	}
+
+	return c.Render("auth/logins", data)
 }
 
 // Logout handles logout requests
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	sessionID := c.Cookies("session_id")
+	sessionID := middleware.GetSessionFromCookie(c)
 	if sessionID != "" {
-		_ = h.authService.Logout(c.Context(), sessionID)
+		_ = h.authService.Logout(c.UserContext(), sessionID)
 	}
 
 	// Clear cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: "Strict",
-		MaxAge:   -1,
-	})
+	middleware.ClearSessionCookie(c)
 
 	return c.Redirect("/login")
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientSnippet is a copy-paste configuration for a DDNS client
+type ClientSnippet struct {
+	Name   string
+	Config string
+}
+
+// clientUsername is sent as the Basic Auth username; only the token is checked
+const clientUsername = "admin"
+
+// clientSnippets returns ready-to-use configurations for common DDNS clients
+// with the hostname, token and public base URL filled in
+func clientSnippets(c *fiber.Ctx, hostname, token string) []ClientSnippet {
+	base := publicBaseURL(c)
+	https := strings.HasPrefix(base, "https://")
+	server := strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://")
+
+	ssl, useHTTPS := "no", "0"
+	if https {
+		ssl, useHTTPS = "yes", "1"
+	}
+
+	return []ClientSnippet{
+		{
+			Name:   "curl",
+			Config: fmt.Sprintf("curl -u '%s:%s' '%s/nic/update?hostname=%s'", clientUsername, token, base, hostname),
+		},
+		{
+			Name: "ddclient",
+			Config: fmt.Sprintf(`protocol=dyndns2
+use=web, web=%s/ip
+server=%s
+ssl=%s
+login=%s
+password='%s'
+%s`, base, server, ssl, clientUsername, token, hostname),
+		},
+		{
+			Name: "OpenWRT (/etc/config/ddns)",
+			Config: fmt.Sprintf(`config service 'route53_ddns'
+	option enabled '1'
+	option lookup_host '%s'
+	option domain '%s'
+	option username '%s'
+	option password '%s'
+	option update_url 'http://[USERNAME]:[PASSWORD]@%s/nic/update?hostname=[DOMAIN]&myip=[IP]'
+	option use_https '%s'
+	option ip_source 'web'
+	option ip_url '%s/ip'
+	option interface 'wan'`, hostname, hostname, clientUsername, token, server, useHTTPS, base),
+		},
+		{
+			Name: "pfSense (Services > Dynamic DNS, type Custom)",
+			Config: fmt.Sprintf(`Username:     %s
+Password:     %s
+Update URL:   %s/nic/update?hostname=%s&myip=%%IP%%
+Result Match: good %%IP%%|nochg %%IP%%`, clientUsername, token, base, hostname),
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DashboardHandler handles the dashboard summary page
+type DashboardHandler struct {
+	ddnsService *service.DDNSService
+	authService *service.AuthService
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler() *DashboardHandler {
+	return &DashboardHandler{
+		ddnsService: service.NewDDNSService(),
+		authService: service.NewAuthService(),
+	}
+}
+
+// Dashboard renders record counts, recent activity, and the last login
+func (h *DashboardHandler) Dashboard(c *fiber.Ctx) error {
+	username, _ := c.Locals("username").(string)
+
+	data := fiber.Map{
+		"PageTitle":   "Dashboard - Dynamic DNS",
+		"CurrentPath": "/",
+		"IsLoggedIn":  true,
+		"Username":    username,
+		"CSRFToken":   c.Locals("csrf_token"),
+		"LastLogin":   h.authService.LastLogin(c.UserContext(), username),
+		"ReadOnly":    service.ReadOnly(),
+	}
+
+	summary, err := h.ddnsService.Summary(c.UserContext(), 5)
+	if err != nil {
+		data["FlashError"] = "Failed to load summary: " + err.Error()
+	} else {
+		data["Summary"] = summary
+	}
+
+	return c.Render("dashboard/index", data)
+}
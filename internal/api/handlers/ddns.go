@@ -1,11 +1,21 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"dynamic-route-53-dns/internal/database"
 	"dynamic-route-53-dns/internal/service"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // DDNSHandler handles DDNS management routes
@@ -24,7 +34,15 @@ func NewDDNSHandler() *DDNSHandler {
 
 // ListDDNS renders the DDNS list page
 func (h *DDNSHandler) ListDDNS(c *fiber.Ctx) error {
-	records, err := h.ddnsService.ListDDNSRecords(c.Context())
+	tag := c.Query("tag")
+
+	var records []database.DDNSRecord
+	var err error
+	if tag != "" {
+		records, err = h.ddnsService.ListDDNSRecordsByTag(c.UserContext(), tag)
+	} else {
+		records, err = h.ddnsService.ListDDNSRecords(c.UserContext())
+	}
 	if err != nil {
 		return c.Render("ddns/list", fiber.Map{
 			"PageTitle":   "DDNS Records - Dynamic DNS",
@@ -32,6 +50,7 @@ func (h *DDNSHandler) ListDDNS(c *fiber.Ctx) error {
 			"IsLoggedIn":  true,
 			"Username":    c.Locals("username"),
 			"CSRFToken":   c.Locals("csrf_token"),
+			"TagFilter":   tag,
 			"FlashError":  "Failed to load records: " + err.Error(),
 		})
 	}
@@ -42,13 +61,14 @@ func (h *DDNSHandler) ListDDNS(c *fiber.Ctx) error {
 		"IsLoggedIn":  true,
 		"Username":    c.Locals("username"),
 		"CSRFToken":   c.Locals("csrf_token"),
+		"TagFilter":   tag,
 		"Records":     records,
 	})
 }
 
-// NewDDNSForm renders the new DDNS form
+// NewDDNSForm renders the new DDNS form, optionally pre-filled from ?clone=<hostname>
 func (h *DDNSHandler) NewDDNSForm(c *fiber.Ctx) error {
-	zones, err := h.zoneService.ListZones(c.Context())
+	zones, err := h.zoneService.ListZones(c.UserContext())
 	if err != nil {
 		return c.Render("ddns/new", fiber.Map{
 			"PageTitle":   "New DDNS Record - Dynamic DNS",
@@ -60,15 +80,32 @@ func (h *DDNSHandler) NewDDNSForm(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Render("ddns/new", fiber.Map{
-		"PageTitle":   "New DDNS Record - Dynamic DNS",
-		"CurrentPath": "/ddns",
-		"IsLoggedIn":  true,
-		"Username":    c.Locals("username"),
-		"CSRFToken":   c.Locals("csrf_token"),
-		"Zones":       zones,
-		"DefaultTTL":  60,
-	})
+	data := fiber.Map{
+		"PageTitle":      "New DDNS Record - Dynamic DNS",
+		"CurrentPath":    "/ddns",
+		"IsLoggedIn":     true,
+		"Username":       c.Locals("username"),
+		"CSRFToken":      c.Locals("csrf_token"),
+		"Zones":          zones,
+		"DefaultTTL":     service.DefaultTTL(),
+		"MinTokenLength": service.MinTokenLength(),
+		"IdempotencyKey": uuid.New().String(),
+	}
+
+	// Pre-fill zone and TTL from an existing record. The hostname is left
+	// blank and the token is never copied; create issues a fresh one.
+	if source := c.Query("clone"); source != "" {
+		record, err := h.ddnsService.GetDDNSRecord(c.UserContext(), source)
+		if err != nil {
+			data["FlashError"] = "Failed to load record to clone: " + err.Error()
+		} else if record != nil {
+			data["CloneFrom"] = record.Hostname
+			data["ZoneID"] = record.ZoneID
+			data["TTL"] = record.TTL
+		}
+	}
+
+	return c.Render("ddns/new", data)
 }
 
 // CreateDDNS creates a new DDNS record
@@ -77,75 +114,131 @@ func (h *DDNSHandler) CreateDDNS(c *fiber.Ctx) error {
 	zoneID := c.FormValue("zone_id")
 	ttlStr := c.FormValue("ttl")
 	initialIP := c.FormValue("ip")
+	description := c.FormValue("description")
+	tagsInput := c.FormValue("tags")
+
+	ttl := service.DefaultTTL()
+	ttlValid := true
+	if ttlStr != "" {
+		parsed, err := strconv.ParseInt(ttlStr, 10, 64)
+		ttl, ttlValid = parsed, err == nil
+	}
 
-	ttl, err := strconv.ParseInt(ttlStr, 10, 64)
-	if err != nil {
-		ttl = 60
+	tags, tagsErr := service.ParseTags(tagsInput)
+	username, _ := c.Locals("username").(string)
+	config := &service.DDNSConfig{
+		Hostname:       hostname,
+		ZoneID:         zoneID,
+		TTL:            ttl,
+		InitialIP:      initialIP,
+		Description:    description,
+		Tags:           tags,
+		AllowPrivate:   c.FormValue("allow_private") == "on",
+		Wildcard:       c.FormValue("wildcard") == "on",
+		Token:          c.FormValue("token"),
+		Overwrite:      c.FormValue("overwrite") == "on",
+		IdempotencyKey: idempotencyKey(c),
+		Username:       username,
 	}
 
-	result := h.ddnsService.CreateDDNSRecord(c.Context(), &service.DDNSConfig{
-		Hostname:  hostname,
-		ZoneID:    zoneID,
-		TTL:       ttl,
-		InitialIP: initialIP,
-	})
+	// Report every invalid field at once rather than one per submission
+	fieldErrors := h.ddnsService.ValidateDDNSInput(c.UserContext(), config)
+	if !ttlValid || tagsErr != nil {
+		if fieldErrors == nil {
+			fieldErrors = make(map[string]string)
+		}
+		if !ttlValid {
+			fieldErrors["ttl"] = "TTL must be a whole number of seconds"
+		}
+		if tagsErr != nil {
+			fieldErrors["tags"] = tagsErr.Error()
+		}
+	}
+
+	var result *service.CreateDDNSResult
+	if fieldErrors != nil {
+		result = &service.CreateDDNSResult{Error: "Please correct the highlighted fields"}
+	} else {
+		result = h.ddnsService.CreateDDNSRecord(c.UserContext(), config)
+	}
 
 	if !result.Success {
-		zones, _ := h.zoneService.ListZones(c.Context())
+		zones, _ := h.zoneService.ListZones(c.UserContext())
 		return c.Render("ddns/new", fiber.Map{
-			"PageTitle":   "New DDNS Record - Dynamic DNS",
-			"CurrentPath": "/ddns",
-			"IsLoggedIn":  true,
-			"Username":    c.Locals("username"),
-			"CSRFToken":   c.Locals("csrf_token"),
-			"FlashError":  result.Error,
-			"Zones":       zones,
-			"Hostname":    hostname,
-			"ZoneID":      zoneID,
-			"TTL":         ttl,
-			"IP":          initialIP,
+			"PageTitle":      "New DDNS Record - Dynamic DNS",
+			"CurrentPath":    "/ddns",
+			"IsLoggedIn":     true,
+			"Username":       c.Locals("username"),
+			"CSRFToken":      c.Locals("csrf_token"),
+			"FlashError":     result.Error,
+			"Errors":         fieldErrors,
+			"Zones":          zones,
+			"Hostname":       hostname,
+			"ZoneID":         zoneID,
+			"TTL":            ttlStr,
+			"IP":             initialIP,
+			"Description":    description,
+			"Tags":           tagsInput,
+			"AllowPrivate":   c.FormValue("allow_private") == "on",
+			"Wildcard":       c.FormValue("wildcard") == "on",
+			"Conflict":       errors.Is(result.Err, service.ErrRecordExists),
+			"IdempotencyKey": uuid.New().String(),
+			"DefaultTTL":     service.DefaultTTL(),
+			"MinTokenLength": service.MinTokenLength(),
 		})
 	}
 
-	// Show the token page (token is only shown once)
+	// Show the token through its one-time reveal, which a retried request
+	// shares: the token stays visible until the user acknowledges saving it
 	// Use the hostname from result in case it was modified (e.g., auto-suffix added)
 	displayHostname := hostname
 	if result.Hostname != "" {
 		displayHostname = result.Hostname
 	}
-	return c.Render("ddns/token", fiber.Map{
-		"PageTitle":   "Token Created - Dynamic DNS",
-		"CurrentPath": "/ddns",
-		"IsLoggedIn":  true,
-		"Username":    c.Locals("username"),
-		"CSRFToken":   c.Locals("csrf_token"),
-		"Hostname":    displayHostname,
-		"Token":       result.Token,
-		"ServerURL":   c.Hostname(),
-	})
+	if result.RevealID != "" {
+		return c.Redirect("/ddns/"+displayHostname+"/token/"+result.RevealID, fiber.StatusSeeOther)
+	}
+
+	// Without a reveal a retry can't show the token again
+	if result.Replayed {
+		return renderRevealed(c, displayHostname)
+	}
+	return renderToken(c, displayHostname, result.Token, false, "")
 }
 
 // DDNSDetail renders the DDNS detail page
 func (h *DDNSHandler) DDNSDetail(c *fiber.Ctx) error {
 	hostname := c.Params("hostname")
 
-	record, err := h.ddnsService.GetDDNSRecord(c.Context(), hostname)
-	if err != nil || record == nil {
-		return c.Redirect("/ddns")
+	record, err := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return service.ErrRecordNotFound
 	}
 
-	history, _ := h.ddnsService.GetUpdateHistory(c.Context(), hostname, 50)
+	data := fiber.Map{
+		"PageTitle":        hostname + " - Dynamic DNS",
+		"CurrentPath":      "/ddns",
+		"IsLoggedIn":       true,
+		"Username":         c.Locals("username"),
+		"CSRFToken":        c.Locals("csrf_token"),
+		"Record":           record,
+		"ServerURL":        serverHost(c),
+		"UpdateURL":        updateURL(c),
+		"DefaultRateLimit": service.DefaultUpdateRateLimit,
+	}
 
-	return c.Render("ddns/detail", fiber.Map{
-		"PageTitle":   hostname + " - Dynamic DNS",
-		"CurrentPath": "/ddns",
-		"IsLoggedIn":  true,
-		"Username":    c.Locals("username"),
-		"CSRFToken":   c.Locals("csrf_token"),
-		"Record":      record,
-		"History":     history,
-		"ServerURL":   c.Hostname(),
-	})
+	// A history failure shouldn't hide the record itself
+	history, err := h.ddnsService.GetUpdateHistory(c.UserContext(), hostname, 50)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load update history for %s: %v\n", hostname, err)
+		data["FlashError"] = "Failed to load update history"
+	}
+	data["History"] = history
+
+	return c.Render("ddns/detail", data)
 }
 
 // UpdateDDNS updates a DDNS record
@@ -155,36 +248,57 @@ func (h *DDNSHandler) UpdateDDNS(c *fiber.Ctx) error {
 	ttlStr := c.FormValue("ttl")
 
 	ttl, _ := strconv.ParseInt(ttlStr, 10, 64)
-
-	err := h.ddnsService.UpdateDDNSRecord(c.Context(), hostname, enabled, ttl)
+	rateLimit, _ := strconv.Atoi(c.FormValue("rate_limit"))
+	minInterval, _ := strconv.ParseInt(c.FormValue("min_update_interval"), 10, 64)
+
+	tags, err := service.ParseTags(c.FormValue("tags"))
+	if err == nil {
+		err = h.ddnsService.UpdateDDNSRecord(c.UserContext(), hostname, &service.DDNSSettings{
+			Enabled:           enabled,
+			TTL:               ttl,
+			RateLimitPerHour:  rateLimit,
+			MinUpdateInterval: minInterval,
+			StaticValues:      splitList(c.FormValue("static_values")),
+			ReverseZoneID:     c.FormValue("reverse_zone_id"),
+			AllowedFamilies:   c.FormValue("allowed_families"),
+			Description:       c.FormValue("description"),
+			Tags:              tags,
+			WebhookURL:        strings.TrimSpace(c.FormValue("webhook_url")),
+			WebhookSecret:     c.FormValue("webhook_secret"),
+		})
+	}
 	if err != nil {
-		record, _ := h.ddnsService.GetDDNSRecord(c.Context(), hostname)
-		history, _ := h.ddnsService.GetUpdateHistory(c.Context(), hostname, 50)
+		record, _ := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+		history, _ := h.ddnsService.GetUpdateHistory(c.UserContext(), hostname, 50)
 		return c.Render("ddns/detail", fiber.Map{
-			"PageTitle":   hostname + " - Dynamic DNS",
-			"CurrentPath": "/ddns",
-			"IsLoggedIn":  true,
-			"Username":    c.Locals("username"),
-			"CSRFToken":   c.Locals("csrf_token"),
-			"Record":      record,
-			"History":     history,
-			"FlashError":  "Failed to update: " + err.Error(),
-			"ServerURL":   c.Hostname(),
+			"PageTitle":        hostname + " - Dynamic DNS",
+			"CurrentPath":      "/ddns",
+			"IsLoggedIn":       true,
+			"Username":         c.Locals("username"),
+			"CSRFToken":        c.Locals("csrf_token"),
+			"Record":           record,
+			"History":          history,
+			"FlashError":       "Failed to update: " + err.Error(),
+			"ServerURL":        serverHost(c),
+			"UpdateURL":        updateURL(c),
+			"DefaultRateLimit": service.DefaultUpdateRateLimit,
 		})
 	}
 
-	record, _ := h.ddnsService.GetDDNSRecord(c.Context(), hostname)
-	history, _ := h.ddnsService.GetUpdateHistory(c.Context(), hostname, 50)
+	record, _ := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+	history, _ := h.ddnsService.GetUpdateHistory(c.UserContext(), hostname, 50)
 	return c.Render("ddns/detail", fiber.Map{
-		"PageTitle":    hostname + " - Dynamic DNS",
-		"CurrentPath":  "/ddns",
-		"IsLoggedIn":   true,
-		"Username":     c.Locals("username"),
-		"CSRFToken":    c.Locals("csrf_token"),
-		"Record":       record,
-		"History":      history,
-		"FlashSuccess": "Record updated successfully",
-		"ServerURL":    c.Hostname(),
+		"PageTitle":        hostname + " - Dynamic DNS",
+		"CurrentPath":      "/ddns",
+		"IsLoggedIn":       true,
+		"Username":         c.Locals("username"),
+		"CSRFToken":        c.Locals("csrf_token"),
+		"Record":           record,
+		"History":          history,
+		"FlashSuccess":     "Record updated successfully",
+		"ServerURL":        serverHost(c),
+		"UpdateURL":        updateURL(c),
+		"DefaultRateLimit": service.DefaultUpdateRateLimit,
 	})
 }
 
@@ -192,8 +306,8 @@ func (h *DDNSHandler) UpdateDDNS(c *fiber.Ctx) error {
 func (h *DDNSHandler) DeleteDDNS(c *fiber.Ctx) error {
 	hostname := c.Params("hostname")
 
-	if err := h.ddnsService.DeleteDDNSRecord(c.Context(), hostname); err != nil {
-		return c.Status(500).SendString("Failed to delete record")
+	if err := h.ddnsService.DeleteDDNSRecord(c.UserContext(), hostname); err != nil {
+		return err
 	}
 
 	return c.Redirect("/ddns")
@@ -203,90 +317,401 @@ func (h *DDNSHandler) DeleteDDNS(c *fiber.Ctx) error {
 func (h *DDNSHandler) RegenerateToken(c *fiber.Ctx) error {
 	hostname := c.Params("hostname")
 
-	token, err := h.ddnsService.RegenerateToken(c.Context(), hostname)
+	var expiresIn time.Duration
+	if value := c.FormValue("expires_in"); value != "" {
+		var err error
+		if expiresIn, err = time.ParseDuration(value); err != nil {
+			return h.renderDetail(c, hostname, "FlashError", "Invalid token expiry: "+value)
+		}
+	}
+
+	token, err := h.ddnsService.RegenerateToken(c.UserContext(), hostname, c.FormValue("token"), expiresIn)
+	if err != nil {
+		return err
+	}
+
+	return showToken(c, h.ddnsService, hostname, token, true)
+}
+
+// showToken stores a new token for one-time reveal and redirects to the
+// token page, so a refresh shows it again rather than resubmitting the form.
+// If the token can't be stored it is rendered directly instead.
+func showToken(c *fiber.Ctx, ddnsService *service.DDNSService, hostname, token string, regenerated bool) error {
+	username, _ := c.Locals("username").(string)
+	id, err := ddnsService.RevealToken(c.UserContext(), username, hostname, token, regenerated)
 	if err != nil {
-		return c.Status(500).SendString("Failed to regenerate token")
+		fmt.Printf("Warning: Failed to store token reveal for %s: %v\n", hostname, err)
+		return renderToken(c, hostname, token, regenerated, "")
 	}
+	return c.Redirect("/ddns/"+hostname+"/token/"+id, fiber.StatusSeeOther)
+}
 
+// renderToken renders the token page; revealID enables the acknowledge button
+func renderToken(c *fiber.Ctx, hostname, token string, regenerated bool, revealID string) error {
+	title := "Token Created - Dynamic DNS"
+	if regenerated {
+		title = "Token Regenerated - Dynamic DNS"
+	}
+
+	// Never let the browser cache a page holding a token
+	c.Set("Cache-Control", "no-store")
 	return c.Render("ddns/token", fiber.Map{
-		"PageTitle":   "Token Regenerated - Dynamic DNS",
+		"PageTitle":   title,
 		"CurrentPath": "/ddns",
 		"IsLoggedIn":  true,
 		"Username":    c.Locals("username"),
 		"CSRFToken":   c.Locals("csrf_token"),
 		"Hostname":    hostname,
 		"Token":       token,
-		"Regenerated": true,
-		"ServerURL":   c.Hostname(),
+		"Regenerated": regenerated,
+		"RevealID":    revealID,
+		"Snippets":    clientSnippets(c, hostname, token),
+		"ServerURL":   serverHost(c),
+		"UpdateURL":   updateURL(c),
 	})
 }
 
-// ManualUpdateIP manually updates the IP address for a DDNS record
-func (h *DDNSHandler) ManualUpdateIP(c *fiber.Ctx) error {
+// TokenPage shows a newly issued token until the user acknowledges it, and
+// afterwards only that it was already revealed
+func (h *DDNSHandler) TokenPage(c *fiber.Ctx) error {
 	hostname := c.Params("hostname")
-	ip := c.FormValue("ip")
+	revealID := c.Params("revealId")
+	username, _ := c.Locals("username").(string)
 
-	err := h.ddnsService.ManualUpdateIP(c.Context(), hostname, ip)
+	reveal, err := h.ddnsService.GetTokenReveal(c.UserContext(), revealID, username, hostname)
+	if err != nil {
+		return err
+	}
+	if reveal == nil || reveal.Acknowledged {
+		return renderRevealed(c, hostname)
+	}
 
-	record, _ := h.ddnsService.GetDDNSRecord(c.Context(), hostname)
-	history, _ := h.ddnsService.GetUpdateHistory(c.Context(), hostname, 50)
+	return renderToken(c, hostname, reveal.Token, reveal.Regenerated, revealID)
+}
 
-	templateData := fiber.Map{
-		"PageTitle":   hostname + " - Dynamic DNS",
+// renderRevealed tells the user a token can't be shown again and must be
+// regenerated
+func renderRevealed(c *fiber.Ctx, hostname string) error {
+	return c.Render("ddns/token", fiber.Map{
+		"PageTitle":   "Token Already Revealed - Dynamic DNS",
 		"CurrentPath": "/ddns",
 		"IsLoggedIn":  true,
 		"Username":    c.Locals("username"),
 		"CSRFToken":   c.Locals("csrf_token"),
-		"Record":      record,
-		"History":     history,
-		"ServerURL":   c.Hostname(),
+		"Hostname":    hostname,
+		"Revealed":    true,
+	})
+}
+
+// AcknowledgeToken confirms a revealed token was saved so it is not shown
+// again, answering the token page's HTMX request with a confirmation
+func (h *DDNSHandler) AcknowledgeToken(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+	username, _ := c.Locals("username").(string)
+
+	if err := h.ddnsService.AcknowledgeToken(c.UserContext(), c.Params("revealId"), username, hostname); err != nil {
+		return err
 	}
 
+	// Lets the page drop its leave-page warning
+	c.Set("HX-Trigger", "tokenAcknowledged")
+	return c.Render("ddns/token_ack", fiber.Map{})
+}
+
+// ManualUpdateIP manually updates the IP address for a DDNS record
+func (h *DDNSHandler) ManualUpdateIP(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+	ip := c.FormValue("ip")
+
+	err := h.ddnsService.ManualUpdateIP(c.UserContext(), hostname, ip)
+
 	if err != nil {
-		templateData["FlashError"] = "Failed to update IP: " + err.Error()
-	} else {
-		templateData["FlashSuccess"] = "IP address updated to " + ip
+		return h.renderDetail(c, hostname, "FlashError", "Failed to update IP: "+err.Error())
 	}
+	return h.renderDetail(c, hostname, "FlashSuccess", "IP address updated to "+ip)
+}
 
-	return c.Render("ddns/detail", templateData)
+// ForceRepublish pushes the stored IP to Route 53 regardless of drift
+func (h *DDNSHandler) ForceRepublish(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+	sourceIP, _ := getSourceIP(c)
+
+	if err := h.ddnsService.ForceRepublish(c.UserContext(), hostname, sourceIP); err != nil {
+		return h.renderDetail(c, hostname, "FlashError", "Failed to republish record: "+err.Error())
+	}
+	return h.renderDetail(c, hostname, "FlashSuccess", "Stored IP republished to Route 53")
+}
+
+// PauseUpdates pauses DDNS updates for a maintenance window
+func (h *DDNSHandler) PauseUpdates(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+
+	duration, err := time.ParseDuration(c.FormValue("duration"))
+	if err == nil {
+		err = h.ddnsService.PauseUpdates(c.UserContext(), hostname, duration)
+	}
+	if err != nil {
+		return h.renderDetail(c, hostname, "FlashError", "Failed to pause updates: "+err.Error())
+	}
+	return h.renderDetail(c, hostname, "FlashSuccess", "Updates paused for "+duration.String())
+}
+
+// ResumeUpdates clears a pause so DDNS updates apply again
+func (h *DDNSHandler) ResumeUpdates(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+
+	if err := h.ddnsService.ResumeUpdates(c.UserContext(), hostname); err != nil {
+		return h.renderDetail(c, hostname, "FlashError", "Failed to resume updates: "+err.Error())
+	}
+	return h.renderDetail(c, hostname, "FlashSuccess", "Updates resumed")
+}
+
+// renderDetail renders the DDNS detail page with a flash message
+func (h *DDNSHandler) renderDetail(c *fiber.Ctx, hostname, flashKey, flash string) error {
+	record, _ := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+	history, _ := h.ddnsService.GetUpdateHistory(c.UserContext(), hostname, 50)
+
+	return c.Render("ddns/detail", fiber.Map{
+		"PageTitle":        hostname + " - Dynamic DNS",
+		"CurrentPath":      "/ddns",
+		"IsLoggedIn":       true,
+		"Username":         c.Locals("username"),
+		"CSRFToken":        c.Locals("csrf_token"),
+		"Record":           record,
+		"History":          history,
+		"ServerURL":        serverHost(c),
+		"UpdateURL":        updateURL(c),
+		"DefaultRateLimit": service.DefaultUpdateRateLimit,
+		flashKey:           flash,
+	})
 }
 
 // DDNSHistory returns the update history (HTMX partial)
 func (h *DDNSHandler) DDNSHistory(c *fiber.Ctx) error {
 	hostname := c.Params("hostname")
 
-	history, err := h.ddnsService.GetUpdateHistory(c.Context(), hostname, 50)
+	history, err := h.ddnsService.GetUpdateHistory(c.UserContext(), hostname, 50)
 	if err != nil {
-		return c.Status(500).SendString("Failed to load history")
+		return err
 	}
 
 	// For HTMX partial response
-	c.Set("Content-Type", "text/html")
+	return c.Render("ddns/history", fiber.Map{
+		"History": history,
+	})
+}
+
+// DDNSHistoryJSON returns the hostname's IP changes for a timeline chart
+// GET /ddns/:hostname/history.json?limit={entries}&since={RFC3339}
+// limit counts log entries scanned, as in the history table (default 50).
+func (h *DDNSHandler) DDNSHistoryJSON(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > service.MaxHistoryLimit {
+		limit = service.MaxHistoryLimit
+	}
+
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "since must be an RFC 3339 timestamp"})
+		}
+		since = parsed
+	}
+
+	changes, err := h.ddnsService.GetIPChanges(c.UserContext(), hostname, int32(limit), since)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"hostname": hostname,
+		"changes":  changes,
+	})
+}
+
+// logExportEntry is one update log in a JSON export
+type logExportEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Status     string    `json:"status"`
+	PreviousIP string    `json:"previous_ip"`
+	NewIP      string    `json:"new_ip"`
+	SourceIP   string    `json:"source_ip"`
+	Country    string    `json:"country,omitempty"`
+	UserAgent  string    `json:"user_agent"`
+	Wildcard   bool      `json:"wildcard"`
+}
+
+// logFilterFromQuery reads ?status=&since=&until= (RFC 3339) for log exports
+func logFilterFromQuery(c *fiber.Ctx) (service.LogFilter, error) {
+	filter := service.LogFilter{Status: c.Query("status")}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*target = parsed
+		}
+	}
+	return filter, nil
+}
+
+// requireRecord returns ErrRecordNotFound unless the hostname is a DDNS record
+func (h *DDNSHandler) requireRecord(c *fiber.Ctx, hostname string) error {
+	record, err := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return service.ErrRecordNotFound
+	}
+	return nil
+}
 
-	html := "<table class=\"min-w-full divide-y divide-gray-700\">"
-	html += "<thead><tr>"
-	html += "<th class=\"px-4 py-2 text-left text-gray-300\">Time</th>"
-	html += "<th class=\"px-4 py-2 text-left text-gray-300\">Previous IP</th>"
-	html += "<th class=\"px-4 py-2 text-left text-gray-300\">New IP</th>"
-	html += "<th class=\"px-4 py-2 text-left text-gray-300\">Source</th>"
-	html += "<th class=\"px-4 py-2 text-left text-gray-300\">Status</th>"
-	html += "</tr></thead><tbody>"
+// ExportHistoryCSV downloads the hostname's full update history as CSV
+// GET /ddns/:hostname/export.csv?status={status}&since={RFC3339}&until={RFC3339}
+func (h *DDNSHandler) ExportHistoryCSV(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
 
-	for _, log := range history {
-		html += "<tr class=\"border-b border-gray-700\">"
-		html += "<td class=\"px-4 py-2 text-gray-300\">" + log.Timestamp.Format("2006-01-02 15:04:05") + "</td>"
-		html += "<td class=\"px-4 py-2 text-gray-300\">" + log.PreviousIP + "</td>"
-		html += "<td class=\"px-4 py-2 text-gray-300\">" + log.NewIP + "</td>"
-		html += "<td class=\"px-4 py-2 text-gray-300\">" + log.SourceIP + "</td>"
-		html += "<td class=\"px-4 py-2 text-gray-300\">" + log.Status + "</td>"
-		html += "</tr>"
+	filter, err := logFilterFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err := h.requireRecord(c, hostname); err != nil {
+		return err
 	}
 
-	html += "</tbody></table>"
+	c.Set("Content-Type", "text/csv")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", hostname+"-history.csv"))
+
+	w := csv.NewWriter(c)
+	_ = w.Write([]string{"timestamp", "status", "previous_ip", "new_ip", "source_ip", "country", "user_agent", "wildcard"})
+	err = h.ddnsService.ExportUpdateLogs(c.UserContext(), hostname, filter, func(entry *database.UpdateLog) error {
+		return w.Write([]string{
+			entry.Timestamp.Format(time.RFC3339),
+			entry.Status,
+			entry.PreviousIP,
+			entry.NewIP,
+			entry.SourceIP,
+			entry.Country,
+			entry.UserAgent,
+			strconv.FormatBool(entry.Wildcard),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// ExportHistoryJSON downloads the hostname's full update history as a JSON array
+// GET /ddns/:hostname/export.json?status={status}&since={RFC3339}&until={RFC3339}
+func (h *DDNSHandler) ExportHistoryJSON(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+
+	filter, err := logFilterFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.requireRecord(c, hostname); err != nil {
+		return err
+	}
 
-	if len(history) == 0 {
-		html = "<p class=\"text-gray-400 text-center py-4\">No update history yet</p>"
+	c.Set("Content-Type", fiber.MIMEApplicationJSON)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", hostname+"-history.json"))
+
+	// Entries are written as they are read so large histories aren't held
+	// in memory twice
+	enc := json.NewEncoder(c)
+	sep := "["
+	err = h.ddnsService.ExportUpdateLogs(c.UserContext(), hostname, filter, func(entry *database.UpdateLog) error {
+		if _, err := c.WriteString(sep); err != nil {
+			return err
+		}
+		sep = ","
+		return enc.Encode(logExportEntry{
+			Timestamp:  entry.Timestamp,
+			Status:     entry.Status,
+			PreviousIP: entry.PreviousIP,
+			NewIP:      entry.NewIP,
+			SourceIP:   entry.SourceIP,
+			Country:    entry.Country,
+			UserAgent:  entry.UserAgent,
+			Wildcard:   entry.Wildcard,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if sep == "[" {
+		_, err = c.WriteString("[]\n")
+	} else {
+		_, err = c.WriteString("]\n")
 	}
+	return err
+}
 
-	return c.SendString(html)
+// DDNSResolve checks what the hostname resolves to publicly (HTMX partial)
+func (h *DDNSHandler) DDNSResolve(c *fiber.Ctx) error {
+	hostname := c.Params("hostname")
+
+	record, err := h.ddnsService.GetDDNSRecord(c.UserContext(), hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return service.ErrRecordNotFound
+	}
+
+	return c.Render("ddns/resolve", fiber.Map{
+		"Resolve": service.CheckResolution(c.UserContext(), hostname, record.CurrentIP),
+	})
+}
+
+// idempotencyKey returns the request's idempotency key from the
+// Idempotency-Key header or the idempotency_key form field
+func idempotencyKey(c *fiber.Ctx) string {
+	key := c.Get("Idempotency-Key")
+	if key == "" {
+		key = c.FormValue("idempotency_key")
+	}
+	if len(key) > 128 {
+		return ""
+	}
+	return key
+}
+
+// publicBaseURL returns the externally visible base URL of the service from
+// PUBLIC_BASE_URL, falling back to the request's scheme and host. Behind
+// CloudFront or API Gateway the Host header can be an internal domain.
+func publicBaseURL(c *fiber.Ctx) string {
+	if base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"); base != "" {
+		return base
+	}
+	return c.Protocol() + "://" + c.Hostname()
+}
+
+// serverHost returns the host clients should be configured with
+func serverHost(c *fiber.Ctx) string {
+	if u, err := url.Parse(publicBaseURL(c)); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return c.Hostname()
+}
+
+// updateURL returns the public DynDNS2 update endpoint URL
+func updateURL(c *fiber.Ctx) string {
+	return publicBaseURL(c) + "/nic/update"
+}
+
+// splitList splits a comma- or whitespace-separated form value into its non-empty items
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
 }
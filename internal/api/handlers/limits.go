@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitsHandler handles the admin rate-limit and lockout tooling
+type LimitsHandler struct {
+	limitsService *service.LimitsService
+}
+
+// NewLimitsHandler creates a new limits handler
+func NewLimitsHandler() *LimitsHandler {
+	return &LimitsHandler{
+		limitsService: service.NewLimitsService(),
+	}
+}
+
+// ShowLimits renders rate-limit and lockout state for the queried hostname,
+// username and client IP
+func (h *LimitsHandler) ShowLimits(c *fiber.Ctx) error {
+	return h.render(c, "", "")
+}
+
+// ClearHostname resets a hostname's rate limit counters
+func (h *LimitsHandler) ClearHostname(c *fiber.Ctx) error {
+	hostname := strings.ToLower(strings.TrimSpace(c.FormValue("hostname")))
+	if err := h.limitsService.ClearHostnameRateLimits(c.UserContext(), hostname); err != nil {
+		return h.render(c, "FlashError", "Failed to clear rate limits: "+err.Error())
+	}
+	return c.Redirect("/admin/limits?hostname=" + url.QueryEscape(hostname))
+}
+
+// ClearUsername lifts a username's login lockout for a client IP
+func (h *LimitsHandler) ClearUsername(c *fiber.Ctx) error {
+	username := strings.TrimSpace(c.FormValue("username"))
+	ip := strings.TrimSpace(c.FormValue("ip"))
+	if err := h.limitsService.ClearLoginLockout(c.UserContext(), username, ip); err != nil {
+		return h.render(c, "FlashError", "Failed to clear lockout: "+err.Error())
+	}
+	return c.Redirect("/admin/limits?username=" + url.QueryEscape(username) + "&ip=" + url.QueryEscape(ip))
+}
+
+// ClearIP resets the lockout alert window for a client IP
+func (h *LimitsHandler) ClearIP(c *fiber.Ctx) error {
+	ip := strings.TrimSpace(c.FormValue("ip"))
+	if err := h.limitsService.ClearIPLockoutAlert(c.UserContext(), ip); err != nil {
+		return h.render(c, "FlashError", "Failed to clear lockout alert: "+err.Error())
+	}
+	return c.Redirect("/admin/limits?ip=" + url.QueryEscape(ip))
+}
+
+// render renders the limits page with an optional flash message
+func (h *LimitsHandler) render(c *fiber.Ctx, flashKey, flash string) error {
+	hostname := strings.ToLower(strings.TrimSpace(c.Query("hostname")))
+	username := strings.TrimSpace(c.Query("username"))
+	ip := strings.TrimSpace(c.Query("ip"))
+
+	data := fiber.Map{
+		"PageTitle":   "Rate Limits - Dynamic DNS",
+		"CurrentPath": "/admin/limits",
+		"IsLoggedIn":  true,
+		"Username":    c.Locals("username"),
+		"CSRFToken":   c.Locals("csrf_token"),
+		"Hostname":    hostname,
+		"LookupUser":  username,
+		"IP":          ip,
+	}
+	if flashKey != "" {
+		data[flashKey] = flash
+	}
+
+	if hostname != "" {
+		states, err := h.limitsService.HostnameRateLimits(c.UserContext(), hostname)
+		if err != nil {
+			data["HostnameError"] = err.Error()
+		}
+		data["HostnameLimits"] = states
+	}
+
+	if username != "" {
+		attempt, err := h.limitsService.LoginLockout(c.UserContext(), username, ip)
+		if err != nil {
+			return err
+		}
+		data["Attempt"] = attempt
+	}
+
+	if ip != "" {
+		state, err := h.limitsService.IPLockoutAlert(c.UserContext(), ip)
+		if err != nil {
+			return err
+		}
+		data["IPLimit"] = state
+	}
+
+	return c.Render("admin/limits", data)
+}
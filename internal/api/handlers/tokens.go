@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TokensHandler handles shared update token routes
+type TokensHandler struct {
+	tokenService *service.TokenService
+	ddnsService  *service.DDNSService
+}
+
+// NewTokensHandler creates a new tokens handler
+func NewTokensHandler() *TokensHandler {
+	return &TokensHandler{
+		tokenService: service.NewTokenService(),
+		ddnsService:  service.NewDDNSService(),
+	}
+}
+
+// ListTokens renders the shared tokens page
+func (h *TokensHandler) ListTokens(c *fiber.Ctx) error {
+	return h.renderList(c, "", "")
+}
+
+// CreateToken creates a shared token and shows its plaintext once
+func (h *TokensHandler) CreateToken(c *fiber.Ctx) error {
+	var hostnames []string
+	for _, hostname := range c.Request().PostArgs().PeekMulti("hostnames") {
+		hostnames = append(hostnames, string(hostname))
+	}
+
+	token, err := h.tokenService.CreateToken(c.UserContext(), c.FormValue("name"), hostnames)
+	if err != nil {
+		return h.renderList(c, "FlashError", "Failed to create token: "+err.Error())
+	}
+
+	return c.Render("tokens/created", fiber.Map{
+		"PageTitle":   "Token Created - Dynamic DNS",
+		"CurrentPath": "/tokens",
+		"IsLoggedIn":  true,
+		"Username":    c.Locals("username"),
+		"CSRFToken":   c.Locals("csrf_token"),
+		"Name":        c.FormValue("name"),
+		"Hostnames":   hostnames,
+		"Token":       token,
+		"UpdateURL":   updateURL(c),
+	})
+}
+
+// RevokeToken deletes a shared token
+func (h *TokensHandler) RevokeToken(c *fiber.Ctx) error {
+	if err := h.tokenService.RevokeToken(c.UserContext(), c.Params("id")); err != nil {
+		return err
+	}
+	return h.renderList(c, "FlashSuccess", "Token revoked")
+}
+
+// TokenStrength checks a token an operator intends to supply themselves
+// POST /api/v1/tokens/strength with form field token
+func (h *TokensHandler) TokenStrength(c *fiber.Ctx) error {
+	if err := service.ValidateTokenStrength(c.FormValue("token")); err != nil {
+		return c.JSON(fiber.Map{"valid": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"valid": true})
+}
+
+// renderList renders the shared tokens page with an optional flash message
+func (h *TokensHandler) renderList(c *fiber.Ctx, flashKey, flash string) error {
+	data := fiber.Map{
+		"PageTitle":   "Shared Tokens - Dynamic DNS",
+		"CurrentPath": "/tokens",
+		"IsLoggedIn":  true,
+		"Username":    c.Locals("username"),
+		"CSRFToken":   c.Locals("csrf_token"),
+	}
+	if flashKey != "" {
+		data[flashKey] = flash
+	}
+
+	tokens, err := h.tokenService.ListTokens(c.UserContext())
+	if err != nil {
+		return err
+	}
+	data["Tokens"] = tokens
+
+	records, err := h.ddnsService.ListDDNSRecords(c.UserContext())
+	if err != nil {
+		return err
+	}
+	data["Records"] = records
+
+	return c.Render("tokens/list", data)
+}
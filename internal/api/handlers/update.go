@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
 	"strings"
+	"sync"
 
+	"dynamic-route-53-dns/internal/route53"
 	"dynamic-route-53-dns/internal/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -22,60 +29,381 @@ func NewUpdateHandler() *UpdateHandler {
 }
 
 // Update handles the DynDNS2 update endpoint
-// GET /nic/update?hostname={hostname}&myip={ip}
-// Authorization: Basic {base64(username:token)}
+// GET /nic/update?hostname={hostname}&myip={ip}&type={A|AAAA|CNAME|TXT|MX}&value={value}&wildcard={ON|OFF|NOCHG}&mx={[priority ]host|NOCHG}&backmx={YES|NO|NOCHG}&dryrun={YES|NO}&offline={YES|NO}&format={json}&verbose={1}
+// Authorization: Basic {base64(username:token)}, where username may stand in for hostname
+// Responds in DynDNS2 plain text unless JSON is requested via format or Accept.
+// verbose appends the record type and TTL to a plain-text good response.
+// Without myip (or value) the source IP is used, unless it is an internal address.
+// type=CNAME, TXT or MX publishes value as that record type instead of an address.
+// offline=YES deletes the hostname's address and wildcard records until the
+// next update.
 func (h *UpdateHandler) Update(c *fiber.Ctx) error {
-	hostname := c.Query("hostname")
+	recordType := strings.ToUpper(c.Query("type"))
+	value := c.Query("value")
+	valueType := service.IsValueType(recordType)
+
+	// For address updates value is another name for myip
 	ip := c.Query("myip")
+	if ip == "" && !valueType {
+		ip = value
+	}
 
 	// If myip not provided, use source IP
-	if ip == "" {
-		ip = c.IP()
+	sourceIP, _ := getSourceIP(c)
+	ipFromSource := ip == "" && !valueType
+	if ipFromSource {
+		ip = sourceIP
+	}
+
+	username, token, ok := parseBasicAuth(c)
+	if !ok {
+		return sendResponse(c, service.ResponseBadAuth, "")
+	}
+
+	hostname, ok := resolveHostname(c.Query("hostname"), username)
+	if !ok {
+		return sendResponse(c, service.ResponseBadAuth, "")
+	}
+
+	// Reject malformed hostnames before touching the database
+	if !service.ValidateFQDN(hostname) {
+		return sendResponse(c, service.ResponseNotFQDN, "")
+	}
+
+	// Get user agent for logging, refusing unidentified or blocklisted clients
+	userAgent := c.Get("User-Agent")
+	if !service.UserAgentAllowed(userAgent) {
+		return sendResponse(c, service.ResponseBadAgent, "")
+	}
+
+	// An optional type hint pins the address family of the record, or
+	// names the type of an explicit value; values are validated by the service
+	if recordType != "" && recordType != "A" && recordType != "AAAA" && !valueType {
+		return sendResponse(c, service.ResponseBadIP, "")
+	}
+
+	// An optional mail exchanger is managed alongside the address record
+	var mx *string
+	if value := c.Query("mx"); value != "" && !strings.EqualFold(value, "NOCHG") {
+		parsed, err := service.ParseMX(value)
+		if err != nil {
+			return sendResponse(c, service.ResponseBadIP, "")
+		}
+		mx = &parsed
+	}
+
+	// Process the update, failing fast rather than running into the Lambda timeout
+	ctx, cancel := context.WithTimeout(c.UserContext(), service.UpdateTimeout())
+	defer cancel()
+	result := h.updateService.ProcessUpdate(ctx, &service.UpdateRequest{
+		Hostname:     hostname,
+		Token:        token,
+		IP:           ip,
+		SourceIP:     sourceIP,
+		UserAgent:    userAgent,
+		RequestID:    requestID(c),
+		IPFromSource: ipFromSource,
+		RecordType:   recordType,
+		Value:        value,
+		Wildcard:     parseOnOff(c.Query("wildcard")),
+		MX:           mx,
+		BackMX:       parseOnOff(c.Query("backmx")),
+		DryRun:       isOn(c.Query("dryrun")),
+		Offline:      isOn(c.Query("offline")),
+	})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Printf("Warning: Update for %s timed out, responding %s\n", hostname, result.Code)
+	}
+
+	if result.RateLimit > 0 {
+		c.Set("X-RateLimit-Limit", fmt.Sprintf("%d", result.RateLimit))
+		c.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", result.RateRemaining))
+	}
+
+	// Dry runs describe the intended change alongside the usual response code
+	if result.Plan != nil {
+		return c.Status(statusForCode(result.Code)).JSON(fiber.Map{
+			"status":  result.Code,
+			"message": result.Message,
+			"plan":    result.Plan,
+		})
+	}
+
+	// JSON clients get the reason a request was refused as malformed
+	if result.Code == service.ResponseBadIP && wantsJSON(c) {
+		return c.Status(statusForCode(result.Code)).JSON(fiber.Map{
+			"status":  result.Code,
+			"message": result.Message,
+		})
+	}
+
+	// Verbose mode extends the good line for operators who log responses;
+	// strict DynDNS2 clients never send it
+	if result.Code == service.ResponseGood && result.TTL > 0 && isOn(c.Query("verbose")) && !wantsJSON(c) {
+		publishedType := string(route53.RecordTypeForIP(result.IP))
+		if valueType {
+			publishedType = recordType
+		}
+		return c.Status(statusForCode(result.Code)).SendString(fmt.Sprintf("%s %s type=%s ttl=%d",
+			result.Code, result.IP, publishedType, result.TTL))
+	}
+
+	return sendResponse(c, result.Code, result.IP)
+}
+
+// Check verifies update credentials without changing DNS
+// GET /nic/check?hostname={hostname}
+// Authorization: Basic {base64(username:token)}
+func (h *UpdateHandler) Check(c *fiber.Ctx) error {
+	username, token, ok := parseBasicAuth(c)
+	if !ok {
+		return sendResponse(c, service.ResponseBadAuth, "")
+	}
+
+	hostname, ok := resolveHostname(c.Query("hostname"), username)
+	if !ok {
+		return sendResponse(c, service.ResponseBadAuth, "")
+	}
+
+	if !service.ValidateFQDN(hostname) {
+		return sendResponse(c, service.ResponseNotFQDN, "")
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), service.UpdateTimeout())
+	defer cancel()
+	result := h.updateService.CheckToken(ctx, hostname, token)
+	return sendResponse(c, result.Code, result.IP)
+}
+
+// requestID returns the ID the requestid middleware assigned to the request
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestid").(string)
+	return id
+}
+
+// sendResponse writes a DynDNS2 response code, as plain text by default or as
+// JSON when the client asks for it. HTTP status is the same in both formats.
+func sendResponse(c *fiber.Ctx, code, ip string) error {
+	c.Status(statusForCode(code))
+
+	if wantsJSON(c) {
+		body := fiber.Map{"status": code}
+		if ip != "" {
+			body["ip"] = ip
+		}
+		return c.JSON(body)
+	}
+
+	// DynDNS2 response format
+	if ip != "" && (code == service.ResponseGood || code == service.ResponseNoChg) {
+		return c.SendString(code + " " + ip)
 	}
+	return c.SendString(code)
+}
+
+// wantsJSON reports whether the client requested a JSON response
+func wantsJSON(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Query("format"), "json") || strings.Contains(c.Get("Accept"), "application/json")
+}
 
-	// Parse Basic Auth
+// parseBasicAuth extracts the username and update token from the Basic Auth
+// header. Only the token authenticates; the username may carry the hostname.
+func parseBasicAuth(c *fiber.Ctx) (string, string, bool) {
 	auth := c.Get("Authorization")
 	if !strings.HasPrefix(auth, "Basic ") {
-		return c.Status(401).SendString(service.ResponseBadAuth)
+		return "", "", false
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
 	if err != nil {
-		return c.Status(401).SendString(service.ResponseBadAuth)
+		return "", "", false
 	}
 
 	parts := strings.SplitN(string(decoded), ":", 2)
 	if len(parts) != 2 {
-		return c.Status(401).SendString(service.ResponseBadAuth)
+		return "", "", false
 	}
 
-	// Username is ignored for DDNS updates, only token matters
-	token := parts[1]
-
-	// Get source IP and user agent for logging
-	sourceIP := c.IP()
-	userAgent := c.Get("User-Agent")
+	return parts[0], parts[1], true
+}
 
-	// Process the update
-	result := h.updateService.ProcessUpdate(c.Context(), hostname, token, ip, sourceIP, userAgent)
+// resolveHostname picks the hostname to update. Clients that omit the
+// hostname parameter may put it in the Basic Auth username instead. When
+// both are given and the username is a hostname, they must agree.
+func resolveHostname(hostname, username string) (string, bool) {
+	if hostname == "" {
+		return username, true
+	}
+	if service.ValidateFQDN(username) && !strings.EqualFold(strings.TrimSuffix(username, "."), strings.TrimSuffix(hostname, ".")) {
+		return "", false
+	}
+	return hostname, true
+}
 
-	// DynDNS2 response format
-	if result.Code == service.ResponseGood || result.Code == service.ResponseNoChg {
-		return c.SendString(result.Code + " " + result.IP)
+// parseOnOff parses a DynDNS2 ON/OFF parameter such as wildcard. NOCHG or
+// an absent value returns nil so the existing setting is kept.
+func parseOnOff(value string) *bool {
+	var enabled bool
+	switch strings.ToUpper(value) {
+	case "ON", "YES", "TRUE", "1":
+		enabled = true
+	case "OFF", "NO", "FALSE", "0":
+		enabled = false
+	default:
+		return nil
 	}
+	return &enabled
+}
 
-	// Error responses
-	switch result.Code {
+// isOn reports whether an ON/OFF parameter is explicitly on
+func isOn(value string) bool {
+	enabled := parseOnOff(value)
+	return enabled != nil && *enabled
+}
+
+// statusForCode maps a DynDNS2 response code to an HTTP status
+func statusForCode(code string) int {
+	switch code {
 	case service.ResponseBadAuth:
-		return c.Status(401).SendString(result.Code)
+		return 401
+	case service.ResponseNoHost:
+		return 404
+	case service.ResponseNotFQDN, service.ResponseBadAgent:
+		return 400
 	case service.ResponseAbuse:
-		return c.Status(429).SendString(result.Code)
+		return 429
+	case service.ResponseDNSErr:
+		return 502
+	case service.ResponseServerErr:
+		return 500
 	default:
-		return c.SendString(result.Code)
+		return 200
 	}
 }
 
 // GetIP returns the caller's IP address
 func (h *UpdateHandler) GetIP(c *fiber.Ctx) error {
-	return c.SendString(c.IP())
+	ip, _ := getSourceIP(c)
+	return c.SendString(ip)
+}
+
+// GetIPJSON returns the caller's IP address along with how it was detected
+func (h *UpdateHandler) GetIPJSON(c *fiber.Ctx) error {
+	ip, source := getSourceIP(c)
+	return c.JSON(fiber.Map{
+		"ip":     ip,
+		"source": source,
+		"family": ipFamily(ip),
+	})
+}
+
+// GetIPv4 returns the caller's IPv4 address. A request carries only one
+// address family, so clients should call this over IPv4-only connectivity.
+func (h *UpdateHandler) GetIPv4(c *fiber.Ctx) error {
+	return sendIPOfFamily(c, "v4")
+}
+
+// GetIPv6 returns the caller's IPv6 address. A request carries only one
+// address family, so clients should call this over IPv6-only connectivity.
+func (h *UpdateHandler) GetIPv6(c *fiber.Ctx) error {
+	return sendIPOfFamily(c, "v6")
+}
+
+// sendIPOfFamily sends the caller's IP if it is of the requested family, or notfound
+func sendIPOfFamily(c *fiber.Ctx, family string) error {
+	ip, _ := getSourceIP(c)
+	if ipFamily(ip) != family {
+		return c.Status(404).SendString("notfound")
+	}
+	return c.SendString(ip)
+}
+
+// Sources an IP address can be detected from
+const (
+	ipSourceForwardedFor = "x-forwarded-for"
+	ipSourceRealIP       = "x-real-ip"
+	ipSourceDirect       = "direct"
+)
+
+var (
+	// trustedProxies lists the networks allowed to set forwarding headers
+	trustedProxies     []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// loadTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of IPs or CIDRs
+func loadTrustedProxies() {
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring invalid TRUSTED_PROXIES entry %q\n", entry)
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+// isTrustedProxy reports whether ip belongs to a configured trusted proxy
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesOnce.Do(loadTrustedProxies)
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSourceIP returns the client IP and where it was taken from. Forwarding
+// headers are only honoured when the connection comes from a trusted proxy:
+// X-Forwarded-For is walked from the right, skipping trusted proxies, then
+// X-Real-IP is tried. With no trusted proxies the connection address is used.
+func getSourceIP(c *fiber.Ctx) (string, string) {
+	remote := c.Context().RemoteIP()
+	if !isTrustedProxy(remote) {
+		return remote.String(), ipSourceDirect
+	}
+
+	if forwarded := c.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			if !isTrustedProxy(hop) {
+				return hop.String(), ipSourceForwardedFor
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(c.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP, ipSourceRealIP
+	}
+
+	return remote.String(), ipSourceDirect
+}
+
+// ipFamily returns "v4" or "v6" for an IP address, or an empty string if invalid
+func ipFamily(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return "v4"
+	default:
+		return "v6"
+	}
 }
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"dynamic-route-53-dns/internal/buildinfo"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Version returns the running build's version, commit, build time and Go version
+func Version(c *fiber.Ctx) error {
+	return c.JSON(buildinfo.Get())
+}
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"dynamic-route-53-dns/internal/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,18 +14,20 @@ import (
 // ZonesHandler handles zone-related routes
 type ZonesHandler struct {
 	zoneService *service.ZoneService
+	ddnsService *service.DDNSService
 }
 
 // NewZonesHandler creates a new zones handler
 func NewZonesHandler() *ZonesHandler {
 	return &ZonesHandler{
 		zoneService: service.NewZoneService(),
+		ddnsService: service.NewDDNSService(),
 	}
 }
 
 // ListZones renders the zones list page
 func (h *ZonesHandler) ListZones(c *fiber.Ctx) error {
-	zones, err := h.zoneService.ListZones(c.Context())
+	zones, err := h.zoneService.ListZoneSummaries(c.UserContext())
 	if err != nil {
 		return c.Render("zones/list", fiber.Map{
 			"PageTitle":   "Zones - Dynamic DNS",
@@ -42,16 +49,49 @@ func (h *ZonesHandler) ListZones(c *fiber.Ctx) error {
 	})
 }
 
+// zoneJSON is the JSON representation of a hosted zone
+type zoneJSON struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Private     bool   `json:"private"`
+	RecordCount int64  `json:"record_count"`
+}
+
+// ListZonesJSON returns the hosted zones as JSON
+// GET /api/v1/zones?onlyPublic=true
+func (h *ZonesHandler) ListZonesJSON(c *fiber.Ctx) error {
+	zones, err := h.zoneService.ListZones(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	onlyPublic := c.QueryBool("onlyPublic")
+	result := make([]zoneJSON, 0, len(zones))
+	for _, zone := range zones {
+		if onlyPublic && zone.IsPrivate {
+			continue
+		}
+		result = append(result, zoneJSON{
+			ID:          zone.ID,
+			Name:        zone.Name,
+			Private:     zone.IsPrivate,
+			RecordCount: zone.RecordCount,
+		})
+	}
+
+	return c.JSON(fiber.Map{"zones": result})
+}
+
 // ZoneDetail renders the zone detail page with records
 func (h *ZonesHandler) ZoneDetail(c *fiber.Ctx) error {
 	zoneID := c.Params("zoneId")
 
-	zone, err := h.zoneService.GetZone(c.Context(), zoneID)
+	zone, err := h.zoneService.GetZone(c.UserContext(), zoneID)
 	if err != nil || zone == nil {
 		return c.Redirect("/zones")
 	}
 
-	records, err := h.zoneService.GetZoneRecords(c.Context(), zoneID)
+	records, err := h.zoneService.GetZoneRecords(c.UserContext(), zoneID)
 	if err != nil {
 		return c.Render("zones/detail", fiber.Map{
 			"PageTitle":   zone.Name + " - Dynamic DNS",
@@ -64,6 +104,185 @@ func (h *ZonesHandler) ZoneDetail(c *fiber.Ctx) error {
 		})
 	}
 
+	// The page embeds the CSRF token, so it is part of the tag alongside the records
+	csrfToken, _ := c.Locals("csrf_token").(string)
+	if etag := service.RecordsETag(records, zone.Name, csrfToken); etag != "" {
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+		if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+	}
+
+	return c.Render("zones/detail", fiber.Map{
+		"PageTitle":   zone.Name + " - Dynamic DNS",
+		"CurrentPath": "/zones",
+		"IsLoggedIn":  true,
+		"Username":    c.Locals("username"),
+		"CSRFToken":   c.Locals("csrf_token"),
+		"Zone":        zone,
+		"Records":     records,
+		"RecordTypes": service.EditableRecordTypes,
+	})
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportRecordsCSV exports a zone's records as CSV, one row per value
+func (h *ZonesHandler) ExportRecordsCSV(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	zone, err := h.zoneService.GetZone(c.UserContext(), zoneID)
+	if err != nil || zone == nil {
+		return service.ErrZoneNotFound
+	}
+
+	records, err := h.zoneService.GetZoneRecords(c.UserContext(), zoneID)
+	if err != nil {
+		return err
+	}
+
+	c.Set("Content-Type", "text/csv")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", zone.Name+"-records.csv"))
+
+	w := csv.NewWriter(c)
+	_ = w.Write([]string{"name", "type", "ttl", "value"})
+	for _, record := range records {
+		ttl := strconv.FormatInt(record.TTL, 10)
+		if record.Alias != nil {
+			_ = w.Write([]string{record.Name, record.Type, ttl, "ALIAS " + record.Alias.DNSName})
+			continue
+		}
+		for _, value := range record.Values {
+			_ = w.Write([]string{record.Name, record.Type, ttl, value})
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// UpsertAlias creates or updates an alias record in a zone
+func (h *ZonesHandler) UpsertAlias(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	err := h.zoneService.UpsertAlias(c.UserContext(), zoneID, aliasFromForm(c))
+	if err != nil {
+		return h.renderZoneDetail(c, zoneID, "FlashError", "Failed to save alias: "+err.Error())
+	}
+
+	return h.renderZoneDetail(c, zoneID, "FlashSuccess", "Alias record saved")
+}
+
+// DeleteAlias deletes an alias record from a zone
+func (h *ZonesHandler) DeleteAlias(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	err := h.zoneService.DeleteAlias(c.UserContext(), zoneID, aliasFromForm(c))
+	if err != nil {
+		return h.renderZoneDetail(c, zoneID, "FlashError", "Failed to delete alias: "+err.Error())
+	}
+
+	return h.renderZoneDetail(c, zoneID, "FlashSuccess", "Alias record deleted")
+}
+
+// UpsertRecord creates or edits an A, AAAA, CNAME, or TXT record in a zone
+func (h *ZonesHandler) UpsertRecord(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	record := recordFromForm(c)
+	record.Values = splitLines(c.FormValue("values"))
+
+	if err := h.zoneService.UpsertRecord(c.UserContext(), zoneID, record); err != nil {
+		return h.renderZoneDetail(c, zoneID, "FlashError", "Failed to save record: "+err.Error())
+	}
+
+	return h.renderZoneDetail(c, zoneID, "FlashSuccess", record.RecordType+" record "+record.Name+" saved")
+}
+
+// DeleteRecord deletes an A, AAAA, CNAME, or TXT record from a zone
+func (h *ZonesHandler) DeleteRecord(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	record := recordFromForm(c)
+	for _, value := range c.Request().PostArgs().PeekMulti("value") {
+		record.Values = append(record.Values, string(value))
+	}
+
+	if err := h.zoneService.DeleteRecord(c.UserContext(), zoneID, record); err != nil {
+		return h.renderZoneDetail(c, zoneID, "FlashError", "Failed to delete record: "+err.Error())
+	}
+
+	return h.renderZoneDetail(c, zoneID, "FlashSuccess", record.RecordType+" record "+record.Name+" deleted")
+}
+
+// ImportRecord adopts an existing A or AAAA record as a DDNS record and shows
+// its new update token once. The live record is not changed.
+func (h *ZonesHandler) ImportRecord(c *fiber.Ctx) error {
+	zoneID := c.Params("zoneId")
+
+	result := h.ddnsService.AdoptDDNSRecord(c.UserContext(), zoneID, c.FormValue("name"), c.FormValue("type"))
+	if !result.Success {
+		return h.renderZoneDetail(c, zoneID, "FlashError", "Failed to import record: "+result.Error)
+	}
+
+	return showToken(c, h.ddnsService, result.Hostname, result.Token, false)
+}
+
+// recordFromForm reads the name, type, and TTL of a record from a submitted form
+func recordFromForm(c *fiber.Ctx) *service.RecordConfig {
+	ttl, _ := strconv.ParseInt(c.FormValue("ttl"), 10, 64)
+	return &service.RecordConfig{
+		Name:       c.FormValue("name"),
+		RecordType: c.FormValue("type"),
+		TTL:        ttl,
+	}
+}
+
+// splitLines splits a textarea value into its non-empty, trimmed lines
+func splitLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// aliasFromForm reads alias record fields from a submitted form
+func aliasFromForm(c *fiber.Ctx) *service.AliasConfig {
+	return &service.AliasConfig{
+		Name:                 c.FormValue("name"),
+		RecordType:           c.FormValue("type", "A"),
+		TargetDNSName:        c.FormValue("target_dns_name"),
+		TargetZoneID:         c.FormValue("target_zone_id"),
+		EvaluateTargetHealth: c.FormValue("evaluate_target_health") == "on",
+	}
+}
+
+// renderZoneDetail renders the zone detail page with a flash message
+func (h *ZonesHandler) renderZoneDetail(c *fiber.Ctx, zoneID, flashKey, flash string) error {
+	zone, err := h.zoneService.GetZone(c.UserContext(), zoneID)
+	if err != nil || zone == nil {
+		return c.Redirect("/zones")
+	}
+
+	records, err := h.zoneService.GetZoneRecords(c.UserContext(), zoneID)
+	if err != nil {
+		flashKey = "FlashError"
+		flash = "Failed to load records: " + err.Error()
+	}
+
 	return c.Render("zones/detail", fiber.Map{
 		"PageTitle":   zone.Name + " - Dynamic DNS",
 		"CurrentPath": "/zones",
@@ -72,5 +291,7 @@ func (h *ZonesHandler) ZoneDetail(c *fiber.Ctx) error {
 		"CSRFToken":   c.Locals("csrf_token"),
 		"Zone":        zone,
 		"Records":     records,
+		"RecordTypes": service.EditableRecordTypes,
+		flashKey:      flash,
 	})
 }
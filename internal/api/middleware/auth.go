@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"strings"
+
+	"dynamic-route-53-dns/internal/route53"
 	"dynamic-route-53-dns/internal/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,30 +12,43 @@ import (
 // RequireAuth middleware ensures the user is authenticated
 func RequireAuth(authService *service.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		sessionID := c.Cookies("session_id")
+		sessionID := GetSessionFromCookie(c)
 		if sessionID == "" {
-			return c.Redirect("/login")
+			return unauthenticated(c)
 		}
 
-		username, valid := authService.ValidateSession(c.Context(), sessionID)
+		username, valid := authService.ValidateSession(c.UserContext(), sessionID)
+		if valid {
+			// Signed sessions are reissued before they expire
+			refreshed, ok := authService.RefreshSession(c.UserContext(), sessionID)
+			if !ok {
+				valid = false
+			} else if refreshed != "" {
+				SetSessionCookie(c, refreshed)
+			}
+		}
 		if !valid {
 			// Clear invalid cookie
-			c.Cookie(&fiber.Cookie{
-				Name:     "session_id",
-				Value:    "",
-				Path:     "/",
-				HTTPOnly: true,
-				Secure:   true,
-				SameSite: "Strict",
-				MaxAge:   -1,
-			})
-			return c.Redirect("/login")
+			ClearSessionCookie(c)
+			return unauthenticated(c)
 		}
 
 		// Store username in context for handlers
 		c.Locals("username", username)
 		c.Locals("is_logged_in", true)
 
+		// Attribute Route 53 changes made from the UI to the signed-in user
+		requestID, _ := c.Locals("requestid").(string)
+		c.SetUserContext(route53.WithChangeSource(c.UserContext(), "admin "+username+" req "+requestID))
+
 		return c.Next()
 	}
 }
+
+// unauthenticated sends JSON API clients a 401 and everyone else to the login page
+func unauthenticated(c *fiber.Ctx) error {
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "authentication required"})
+	}
+	return c.Redirect("/login")
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportPathSuffix marks routes that accept bulk imports and are held to the
+// larger import limit instead of the default body limit
+const ImportPathSuffix = "/import"
+
+// BodyLimit rejects request bodies larger than limit bytes with 413. Import
+// routes are exempt; they are bounded by the app-wide limit instead.
+func BodyLimit(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if strings.HasSuffix(c.Path(), ImportPathSuffix) {
+			return c.Next()
+		}
+		if c.Request().Header.ContentLength() > limit || len(c.Body()) > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).SendString("Request body too large")
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// APICORS allows cross-origin calls to the JSON API from the comma-separated
+// origins in API_CORS_ORIGINS. Credentials are never allowed, so browsers
+// don't send the session cookie cross-origin. Without origins configured it
+// adds no CORS headers. Mount it on /api only; HTML routes stay same-origin.
+func APICORS() fiber.Handler {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("API_CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(origins) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return cors.New(cors.Config{
+		AllowOrigins:     strings.Join(origins, ","),
+		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:     "Authorization,Content-Type",
+		AllowCredentials: false,
+		MaxAge:           600,
+	})
+}
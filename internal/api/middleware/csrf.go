@@ -54,7 +54,7 @@ func CSRF() fiber.Handler {
 			c.Cookie(&fiber.Cookie{
 				Name:     DefaultCSRFConfig.CookieName,
 				Value:    token,
-				Path:     "/",
+				Path:     CookiePath(),
 				HTTPOnly: false, // Needs to be readable by JS for HTMX
 				Secure:   true,
 				SameSite: "Strict",
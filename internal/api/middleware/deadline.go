@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeadlineHeader carries the Lambda invocation's deadline, in Unix
+// milliseconds, from the Lambda handler into the Fiber app, which otherwise
+// never sees the invocation context
+const DeadlineHeader = "X-Lambda-Deadline"
+
+// deadlineHeadroom is kept free before the Lambda deadline so a slow
+// downstream call still leaves time to send a response
+const deadlineHeadroom = time.Second
+
+// LambdaDeadline bounds the request's user context by the Lambda invocation
+// deadline, less deadlineHeadroom. Outside Lambda the header is ignored.
+func LambdaDeadline() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") == "" {
+			return c.Next()
+		}
+		ms, err := strconv.ParseInt(c.Get(DeadlineHeader), 10, 64)
+		if err != nil {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithDeadline(c.UserContext(), time.UnixMilli(ms).Add(-deadlineHeadroom))
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"encoding/json"
-	"fmt"
+	"strconv"
 	"time"
 
+	"dynamic-route-53-dns/internal/metrics"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -21,15 +23,23 @@ type LogEntry struct {
 	Latency   string `json:"latency"`
 	IP        string `json:"ip"`
 	UserAgent string `json:"user_agent"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
-// Logging middleware provides structured logging
+// Logging middleware provides structured logging, written to LogOutput()
 func Logging() fiber.Handler {
+	out := LogOutput()
+
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 
-		// Process request
+		// Process request, rendering any error now so its status is logged
 		err := c.Next()
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
 
 		// Build log entry
 		entry := LogEntry{
@@ -42,6 +52,8 @@ func Logging() fiber.Handler {
 			UserAgent: c.Get("User-Agent"),
 			Where:     "ddns:http",
 		}
+		entry.RequestID, _ = c.Locals("requestid").(string)
+		metrics.Inc("ddns_http_requests_total", "method", entry.Method, "status", strconv.Itoa(entry.Status))
 
 		// Add user info if available
 		if username, ok := c.Locals("username").(string); ok && username != "" {
@@ -89,12 +101,20 @@ func Logging() fiber.Handler {
 				entry.What = "ddns_update_failed"
 				entry.Why = "dynamic dns update failed"
 			}
+		case "/nic/check":
+			if entry.Status == 200 {
+				entry.What = "ddns_check"
+				entry.Why = "dynamic dns credentials verified"
+			} else {
+				entry.What = "ddns_check_failed"
+				entry.Why = "dynamic dns credential check failed"
+			}
 		}
 
 		// Output as JSON
 		logJSON, _ := json.Marshal(entry)
-		fmt.Println(string(logJSON))
+		_, _ = out.Write(append(logJSON, '\n'))
 
-		return err
+		return nil
 	}
 }
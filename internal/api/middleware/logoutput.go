@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP log sink batching. Lines are queued and posted as newline-delimited
+// JSON so a slow sink never holds up a request; when the queue is full, new
+// lines are dropped and counted.
+const (
+	sinkQueueSize     = 1024
+	sinkBatchSize     = 100
+	sinkFlushInterval = 2 * time.Second
+	sinkPostTimeout   = 5 * time.Second
+)
+
+var (
+	logOutput     io.Writer
+	logOutputOnce sync.Once
+)
+
+// LogOutput returns the writer request logs go to, chosen once by LOG_OUTPUT:
+// "stdout" (the default), "stderr", "file:<path>" to append to a file, or an
+// http(s) URL to post batches to a log sink
+func LogOutput() io.Writer {
+	logOutputOnce.Do(func() {
+		logOutput = openLogOutput(os.Getenv("LOG_OUTPUT"))
+	})
+	return logOutput
+}
+
+// openLogOutput builds the writer for a LOG_OUTPUT value, falling back to
+// stdout when the destination can't be used
+func openLogOutput(value string) io.Writer {
+	switch {
+	case value == "" || value == "stdout":
+		return os.Stdout
+	case value == "stderr":
+		return os.Stderr
+	case strings.HasPrefix(value, "file:"):
+		f, err := os.OpenFile(strings.TrimPrefix(value, "file:"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Printf("Warning: Failed to open log file, logging to stdout: %v\n", err)
+			return os.Stdout
+		}
+		return f
+	case strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://"):
+		return newHTTPSink(value)
+	default:
+		fmt.Printf("Warning: Ignoring unknown LOG_OUTPUT %q, logging to stdout\n", value)
+		return os.Stdout
+	}
+}
+
+// FlushLogs delivers any log lines still queued for an HTTP sink. Call it on
+// shutdown, and in Lambda before each invocation returns, since the
+// environment is frozen in between; other outputs are unbuffered.
+func FlushLogs(ctx context.Context) {
+	if sink, ok := LogOutput().(*httpSink); ok {
+		sink.flush(ctx)
+	}
+}
+
+// httpSink is an io.Writer that posts log lines to an HTTP endpoint in the
+// background
+type httpSink struct {
+	url     string
+	lines   chan []byte
+	flushed chan chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// newHTTPSink starts the background sender for url
+func newHTTPSink(url string) *httpSink {
+	s := &httpSink{
+		url:     url,
+		lines:   make(chan []byte, sinkQueueSize),
+		flushed: make(chan chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues one log line without blocking
+func (s *httpSink) Write(p []byte) (int, error) {
+	line := append([]byte(nil), p...)
+	select {
+	case s.lines <- line:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+// run collects queued lines and posts them whenever a batch fills, the
+// flush interval passes or a flush is requested
+func (s *httpSink) run() {
+	ticker := time.NewTicker(sinkFlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	count := 0
+	send := func() {
+		if count > 0 {
+			s.post(batch.Bytes())
+			batch.Reset()
+			count = 0
+		}
+	}
+
+	for {
+		select {
+		case line := <-s.lines:
+			batch.Write(line)
+			if count++; count >= sinkBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-s.flushed:
+			for drained := false; !drained; {
+				select {
+				case line := <-s.lines:
+					batch.Write(line)
+					count++
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+// post sends one batch, logging failures and dropped lines to stderr so
+// they don't feed back into the sink
+func (s *httpSink) post(body []byte) {
+	s.mu.Lock()
+	dropped := s.dropped
+	s.dropped = 0
+	s.mu.Unlock()
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: Dropped %d log lines while the log sink queue was full\n", dropped)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkPostTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to build log sink request: %v\n", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to send logs: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		fmt.Fprintf(os.Stderr, "Warning: Log sink returned status %d\n", resp.StatusCode)
+	}
+}
+
+// flush asks the sender to post everything queued and waits until it has,
+// or until ctx ends
+func (s *httpSink) flush(ctx context.Context) {
+	done := make(chan struct{})
+	select {
+	case s.flushed <- done:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
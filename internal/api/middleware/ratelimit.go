@@ -34,8 +34,8 @@ func RateLimit(config ...RateLimitConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		key := cfg.KeyGenerator(c)
 
-		count, exceeded, err := database.IncrementRateLimit(
-			c.Context(),
+		count, exceeded, err := database.GetStore().IncrementRateLimit(
+			c.UserContext(),
 			fmt.Sprintf("ratelimit:%s", key),
 			cfg.Max,
 			cfg.WindowSeconds,
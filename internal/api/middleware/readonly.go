@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"dynamic-route-53-dns/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReadOnly rejects mutating requests with 503 while READ_ONLY is enabled.
+// Reads and the login/logout flow keep working; DynDNS clients receive 911
+// so they retry later.
+func ReadOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !service.ReadOnly() {
+			return c.Next()
+		}
+
+		// The update endpoint mutates DNS even though it is a GET
+		if c.Path() == "/nic/update" {
+			c.Set("Retry-After", "300")
+			return c.Status(fiber.StatusServiceUnavailable).SendString(service.ResponseServerErr)
+		}
+
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if c.Path() == "/login" || c.Path() == "/logout" {
+			return c.Next()
+		}
+
+		c.Set("Retry-After", "300")
+		return c.Status(fiber.StatusServiceUnavailable).SendString("Maintenance in progress: changes are temporarily disabled. Please try again later.")
+	}
+}
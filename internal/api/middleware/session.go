@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessionMaxAge is how long the browser keeps the session cookie, in seconds
+const sessionMaxAge = 86400 // 24 hours
+
+// SessionCookieName returns the session cookie name, overridable with
+// SESSION_COOKIE_NAME so instances sharing a domain don't collide
+func SessionCookieName() string {
+	if name := os.Getenv("SESSION_COOKIE_NAME"); name != "" {
+		return name
+	}
+	return "session_id"
+}
+
+// CookiePath returns the path cookies are scoped to, overridable with
+// COOKIE_PATH when the app is served under a path prefix
+func CookiePath() string {
+	if path := os.Getenv("COOKIE_PATH"); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// GetSessionFromCookie returns the session ID sent by the browser, if any
+func GetSessionFromCookie(c *fiber.Ctx) string {
+	return c.Cookies(SessionCookieName())
+}
+
+// SetSessionCookie stores a session ID in the session cookie
+func SetSessionCookie(c *fiber.Ctx, sessionID string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     SessionCookieName(),
+		Value:    sessionID,
+		Path:     CookiePath(),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Strict",
+		MaxAge:   sessionMaxAge,
+	})
+}
+
+// ClearSessionCookie expires the session cookie
+func ClearSessionCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     SessionCookieName(),
+		Value:    "",
+		Path:     CookiePath(),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Strict",
+		MaxAge:   -1,
+	})
+}
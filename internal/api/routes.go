@@ -1,6 +1,10 @@
 package api
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"dynamic-route-53-dns/internal/api/handlers"
 	"dynamic-route-53-dns/internal/api/middleware"
 	"dynamic-route-53-dns/internal/service"
@@ -15,34 +19,67 @@ func SetupRoutes(app *fiber.App) {
 	zonesHandler := handlers.NewZonesHandler()
 	ddnsHandler := handlers.NewDDNSHandler()
 	updateHandler := handlers.NewUpdateHandler()
+	dashboardHandler := handlers.NewDashboardHandler()
+	tokensHandler := handlers.NewTokensHandler()
+	limitsHandler := handlers.NewLimitsHandler()
 
 	// Initialize auth service for middleware
 	authService := service.NewAuthService()
 
 	// Apply global middleware
 	app.Use(middleware.Logging())
+	app.Use(middleware.SecurityHeaders())
+	app.Use("/api", middleware.APICORS()) // answers preflights before auth
 	app.Use(middleware.CSRF())
+	app.Use(middleware.ReadOnly())
 
 	// Public routes
-	app.Get("/", func(c *fiber.Ctx) error {
-		return c.Redirect("/login")
-	})
 	app.Get("/login", authHandler.LoginPage)
 	app.Post("/login", authHandler.Login)
 	app.Post("/logout", authHandler.Logout)
 
 	// IP endpoint (public)
 	app.Get("/ip", updateHandler.GetIP)
+	app.Get("/ip.json", updateHandler.GetIPJSON)
+	app.Get("/ip4", updateHandler.GetIPv4) // call over IPv4-only connectivity
+	app.Get("/ip6", updateHandler.GetIPv6) // call over IPv6-only connectivity
+
+	// Build metadata (public)
+	app.Get("/version", handlers.Version)
 
 	// DynDNS2 update endpoint (uses Basic Auth)
 	app.Get("/nic/update", updateHandler.Update)
+	app.Get("/nic/check", updateHandler.Check)
 
 	// Protected routes - require authentication
 	protected := app.Group("", middleware.RequireAuth(authService))
 
+	// Dashboard (unauthenticated visitors are sent to /login). ROOT_REDIRECT
+	// may send / elsewhere, leaving the dashboard at /dashboard.
+	if target := rootRedirect(); target != "" {
+		protected.Get("/", func(c *fiber.Ctx) error {
+			return c.Redirect(target)
+		})
+	} else {
+		protected.Get("/", dashboardHandler.Dashboard)
+	}
+	protected.Get("/dashboard", dashboardHandler.Dashboard)
+
+	// Account routes
+	protected.Get("/logins", authHandler.LoginHistory)
+
 	// Zone routes
 	protected.Get("/zones", zonesHandler.ListZones)
 	protected.Get("/zones/:zoneId", zonesHandler.ZoneDetail)
+	protected.Get("/zones/:zoneId/export.csv", zonesHandler.ExportRecordsCSV)
+	protected.Post("/zones/:zoneId/records", zonesHandler.UpsertRecord)
+	protected.Post("/zones/:zoneId/records/delete", zonesHandler.DeleteRecord)
+	protected.Post("/zones/:zoneId/records/import", zonesHandler.ImportRecord)
+	protected.Post("/zones/:zoneId/alias", zonesHandler.UpsertAlias)
+	protected.Post("/zones/:zoneId/alias/delete", zonesHandler.DeleteAlias)
+
+	// JSON API routes
+	protected.Get("/api/v1/zones", zonesHandler.ListZonesJSON)
 
 	// DDNS management routes
 	protected.Get("/ddns", ddnsHandler.ListDDNS)
@@ -54,6 +91,41 @@ func SetupRoutes(app *fiber.App) {
 	protected.Delete("/ddns/:hostname", ddnsHandler.DeleteDDNS)
 	protected.Post("/ddns/:hostname/delete", ddnsHandler.DeleteDDNS) // HTML forms only support GET/POST
 	protected.Post("/ddns/:hostname/update-ip", ddnsHandler.ManualUpdateIP)
+	protected.Post("/ddns/:hostname/republish", ddnsHandler.ForceRepublish)
 	protected.Post("/ddns/:hostname/regenerate-token", ddnsHandler.RegenerateToken)
+	protected.Get("/ddns/:hostname/token/:revealId", ddnsHandler.TokenPage)
+	protected.Post("/ddns/:hostname/token/:revealId/ack", ddnsHandler.AcknowledgeToken)
+	protected.Post("/ddns/:hostname/pause", ddnsHandler.PauseUpdates)
+	protected.Post("/ddns/:hostname/resume", ddnsHandler.ResumeUpdates)
 	protected.Get("/ddns/:hostname/history", ddnsHandler.DDNSHistory)
+	protected.Get("/ddns/:hostname/history.json", ddnsHandler.DDNSHistoryJSON)
+	protected.Get("/ddns/:hostname/export.csv", ddnsHandler.ExportHistoryCSV)
+	protected.Get("/ddns/:hostname/export.json", ddnsHandler.ExportHistoryJSON)
+	protected.Get("/ddns/:hostname/resolve", ddnsHandler.DDNSResolve)
+
+	// Shared update token routes
+	protected.Get("/tokens", tokensHandler.ListTokens)
+	protected.Post("/tokens", tokensHandler.CreateToken)
+	protected.Post("/tokens/:id/delete", tokensHandler.RevokeToken)
+	protected.Post("/api/v1/tokens/strength", tokensHandler.TokenStrength)
+
+	// Admin support tooling
+	protected.Get("/admin/limits", limitsHandler.ShowLimits)
+	protected.Post("/admin/limits/hostname/clear", limitsHandler.ClearHostname)
+	protected.Post("/admin/limits/username/clear", limitsHandler.ClearUsername)
+	protected.Post("/admin/limits/ip/clear", limitsHandler.ClearIP)
+}
+
+// rootRedirect returns ROOT_REDIRECT when it is an internal path such as
+// /ddns or /zones, or an empty string to serve the dashboard at /
+func rootRedirect() string {
+	target := strings.TrimSpace(os.Getenv("ROOT_REDIRECT"))
+	if target == "" || target == "/" {
+		return ""
+	}
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.ContainsAny(target, "\\\r\n") {
+		fmt.Printf("Warning: Ignoring ROOT_REDIRECT %q, must be an internal path\n", target)
+		return ""
+	}
+	return target
 }
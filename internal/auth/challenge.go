@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"dynamic-route-53-dns/internal/secrets"
+)
+
+// ChallengeVerifier verifies a challenge response submitted with the login form
+type ChallengeVerifier interface {
+	// Verify reports whether response is a valid solution for the caller at remoteIP
+	Verify(ctx context.Context, response, remoteIP string) (bool, error)
+
+	// Widget describes how the login page renders the challenge
+	Widget() *ChallengeWidget
+}
+
+// ChallengeWidget describes the client-side widget for a challenge provider
+type ChallengeWidget struct {
+	ScriptURL     string // script that renders the widget
+	Class         string // CSS class of the widget container
+	SiteKey       string // public site key
+	ResponseField string // form field carrying the solved token
+}
+
+// siteverifyVerifier verifies tokens against a siteverify-style endpoint,
+// the protocol shared by Cloudflare Turnstile and hCaptcha
+type siteverifyVerifier struct {
+	verifyURL string
+	secret    string
+	widget    *ChallengeWidget
+	http      *http.Client
+}
+
+// NewChallengeVerifierFromEnv returns the verifier selected by
+// LOGIN_CHALLENGE_PROVIDER ("turnstile" or "hcaptcha") using
+// LOGIN_CHALLENGE_SITE_KEY and LOGIN_CHALLENGE_SECRET, or nil if none is configured.
+func NewChallengeVerifierFromEnv() ChallengeVerifier {
+	provider := strings.ToLower(os.Getenv("LOGIN_CHALLENGE_PROVIDER"))
+	siteKey := os.Getenv("LOGIN_CHALLENGE_SITE_KEY")
+	secret := secrets.Get("LOGIN_CHALLENGE_SECRET")
+	if provider == "" {
+		return nil
+	}
+	if siteKey == "" || secret == "" {
+		fmt.Printf("Warning: LOGIN_CHALLENGE_PROVIDER set without site key and secret, challenges disabled\n")
+		return nil
+	}
+
+	switch provider {
+	case "turnstile":
+		return newSiteverifyVerifier("https://challenges.cloudflare.com/turnstile/v0/siteverify", secret, &ChallengeWidget{
+			ScriptURL:     "https://challenges.cloudflare.com/turnstile/v0/api.js",
+			Class:         "cf-turnstile",
+			SiteKey:       siteKey,
+			ResponseField: "cf-turnstile-response",
+		})
+	case "hcaptcha":
+		return newSiteverifyVerifier("https://api.hcaptcha.com/siteverify", secret, &ChallengeWidget{
+			ScriptURL:     "https://js.hcaptcha.com/1/api.js",
+			Class:         "h-captcha",
+			SiteKey:       siteKey,
+			ResponseField: "h-captcha-response",
+		})
+	default:
+		fmt.Printf("Warning: Unknown LOGIN_CHALLENGE_PROVIDER %q, challenges disabled\n", provider)
+		return nil
+	}
+}
+
+// newSiteverifyVerifier creates a verifier for a siteverify-style endpoint
+func newSiteverifyVerifier(verifyURL, secret string, widget *ChallengeWidget) *siteverifyVerifier {
+	return &siteverifyVerifier{
+		verifyURL: verifyURL,
+		secret:    secret,
+		widget:    widget,
+		http:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify posts the response token to the provider's siteverify endpoint
+func (v *siteverifyVerifier) Verify(ctx context.Context, response, remoteIP string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {response},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("failed to build challenge verification: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify challenge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode challenge verification: %w", err)
+	}
+	return result.Success, nil
+}
+
+// Widget returns the client-side widget description
+func (v *siteverifyVerifier) Widget() *ChallengeWidget {
+	return v.widget
+}
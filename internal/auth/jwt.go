@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// minJWTSecretLength is the shortest JWT_SECRET accepted for HS256 signing
+const minJWTSecretLength = 32
+
+// defaultJWTLifetime is how long a signed session is valid without a refresh
+const defaultJWTLifetime = 15 * time.Minute
+
+// maxSessionAge caps how long refreshes can extend a login, matching the
+// lifetime of DynamoDB sessions
+const maxSessionAge = 24 * time.Hour
+
+// revocationCacheTTL is how long a token found not revoked in DynamoDB is
+// trusted before the revocation list is checked again
+const revocationCacheTTL = 30 * time.Second
+
+// jwtHeader is the fixed, pre-encoded HS256 JOSE header
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// jwtLifetime returns JWT_TTL as a duration, defaulting to 15 minutes
+func jwtLifetime() time.Duration {
+	lifetime, err := time.ParseDuration(os.Getenv("JWT_TTL"))
+	if err != nil || lifetime <= 0 {
+		return defaultJWTLifetime
+	}
+	return lifetime
+}
+
+// sessionClaims are the claims carried in a signed session
+type sessionClaims struct {
+	Subject  string `json:"sub"`
+	ID       string `json:"jti"`
+	AuthTime int64  `json:"auth_time"` // when the user logged in, kept across refreshes
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+}
+
+// jwtSessionManager issues HS256-signed sessions validated in-process.
+// Logout records the token ID in a DynamoDB revocation list. Validation
+// checks that list, caching a negative answer for revocationCacheTTL, so a
+// logged-out token is refused immediately by the instance that handled the
+// logout and within revocationCacheTTL everywhere else.
+type jwtSessionManager struct {
+	store    database.Store
+	secret   []byte
+	lifetime time.Duration
+}
+
+// Revocation state is shared by every manager on the instance, since each
+// service creates its own
+var (
+	revokedTokens sync.Map // token ID -> expiry, tokens revoked on this instance
+	checkedTokens sync.Map // token ID -> when DynamoDB last reported it not revoked
+)
+
+// newJWTSessionManager creates a JWT session manager
+func newJWTSessionManager(store database.Store, secret []byte, lifetime time.Duration) *jwtSessionManager {
+	return &jwtSessionManager{store: store, secret: secret, lifetime: lifetime}
+}
+
+// CreateSession signs a new session for a user
+func (sm *jwtSessionManager) CreateSession(ctx context.Context, username string) (string, error) {
+	return sm.sign(username, time.Now().UTC().Unix())
+}
+
+// ValidateSession verifies the signature and expiry and returns the username
+func (sm *jwtSessionManager) ValidateSession(ctx context.Context, sessionID string) (string, bool) {
+	claims, ok := sm.parse(sessionID)
+	if !ok {
+		return "", false
+	}
+	if sm.isRevoked(ctx, claims.ID) {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// isRevoked reports whether a token ID was revoked, on this instance or in
+// the DynamoDB revocation list
+func (sm *jwtSessionManager) isRevoked(ctx context.Context, id string) bool {
+	if _, revoked := revokedTokens.Load(id); revoked {
+		return true
+	}
+
+	now := time.Now().UTC()
+	if checked, ok := checkedTokens.Load(id); ok && now.Sub(checked.(time.Time)) < revocationCacheTTL {
+		return false
+	}
+
+	revoked, err := sm.store.IsSessionRevoked(ctx, id)
+	if err != nil {
+		// Keep accepting the token; it still expires on its own
+		fmt.Printf("Warning: Failed to check session revocation: %v\n", err)
+		return false
+	}
+	if revoked {
+		revokedTokens.Store(id, now.Add(sm.lifetime))
+		return true
+	}
+
+	checkedTokens.Store(id, now)
+	pruneChecked(now)
+	return false
+}
+
+// RefreshSession reissues a session past half its lifetime, after checking
+// the revocation list directly. Sessions older than maxSessionAge are not
+// renewed.
+func (sm *jwtSessionManager) RefreshSession(ctx context.Context, sessionID string) (string, bool) {
+	claims, ok := sm.parse(sessionID)
+	if !ok {
+		return "", false
+	}
+
+	now := time.Now().UTC()
+	if time.Unix(claims.Expires, 0).Sub(now) > sm.lifetime/2 {
+		return "", true
+	}
+	if now.Sub(time.Unix(claims.AuthTime, 0)) > maxSessionAge {
+		return "", true
+	}
+
+	revoked, err := sm.store.IsSessionRevoked(ctx, claims.ID)
+	if err != nil {
+		// Keep the current token; it is still valid until it expires
+		fmt.Printf("Warning: Failed to check session revocation: %v\n", err)
+		return "", true
+	}
+	if revoked {
+		return "", false
+	}
+
+	refreshed, err := sm.sign(claims.Subject, claims.AuthTime)
+	if err != nil {
+		return "", true
+	}
+	return refreshed, true
+}
+
+// DeleteSession revokes a session until it would have expired
+func (sm *jwtSessionManager) DeleteSession(ctx context.Context, sessionID string) error {
+	claims, ok := sm.parse(sessionID)
+	if !ok {
+		return nil
+	}
+
+	expiresAt := time.Unix(claims.Expires, 0).UTC()
+	revokedTokens.Store(claims.ID, expiresAt)
+	checkedTokens.Delete(claims.ID)
+	pruneRevoked()
+
+	return sm.store.RevokeSession(ctx, claims.ID, expiresAt)
+}
+
+// sign issues a token for username that expires after the session lifetime
+func (sm *jwtSessionManager) sign(username string, authTime int64) (string, error) {
+	now := time.Now().UTC()
+	payload, err := json.Marshal(sessionClaims{
+		Subject:  username,
+		ID:       uuid.New().String(),
+		AuthTime: authTime,
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(sm.lifetime).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session claims: %w", err)
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sm.signature(unsigned), nil
+}
+
+// parse verifies a token's header, signature and expiry and returns its claims
+func (sm *jwtSessionManager) parse(token string) (*sessionClaims, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, false
+	}
+
+	expected := sm.signature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" || claims.ID == "" {
+		return nil, false
+	}
+
+	if !time.Now().UTC().Before(time.Unix(claims.Expires, 0)) {
+		return nil, false
+	}
+
+	return &claims, true
+}
+
+// signature returns the base64url HMAC-SHA256 of the signing input
+func (sm *jwtSessionManager) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, sm.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pruneRevoked drops locally revoked token IDs that have since expired
+func pruneRevoked() {
+	now := time.Now().UTC()
+	revokedTokens.Range(func(key, value any) bool {
+		if expiresAt, ok := value.(time.Time); ok && now.After(expiresAt) {
+			revokedTokens.Delete(key)
+		}
+		return true
+	})
+}
+
+// pruneChecked drops cached revocation checks that are due to be redone
+func pruneChecked(now time.Time) {
+	checkedTokens.Range(func(key, value any) bool {
+		if checked, ok := value.(time.Time); ok && now.Sub(checked) >= revocationCacheTTL {
+			checkedTokens.Delete(key)
+		}
+		return true
+	})
+}
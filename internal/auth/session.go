@@ -5,23 +5,54 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/secrets"
 
 	"github.com/google/uuid"
 )
 
-// SessionManager manages user sessions
-type SessionManager struct{}
+// SessionManager manages user sessions. The session ID is what the session
+// cookie carries: an opaque key in DynamoDB mode or a signed token in JWT mode.
+type SessionManager interface {
+	// CreateSession starts a session for a user and returns its ID
+	CreateSession(ctx context.Context, username string) (string, error)
 
-// NewSessionManager creates a new session manager
-func NewSessionManager() *SessionManager {
-	return &SessionManager{}
+	// ValidateSession validates a session and returns the username
+	ValidateSession(ctx context.Context, sessionID string) (string, bool)
+
+	// RefreshSession returns a replacement session ID when a valid session is
+	// due to be reissued, or false when it can no longer be used
+	RefreshSession(ctx context.Context, sessionID string) (string, bool)
+
+	// DeleteSession ends a session
+	DeleteSession(ctx context.Context, sessionID string) error
+}
+
+// NewSessionManager returns the session manager selected by SESSION_MODE:
+// "jwt" for stateless signed sessions using JWT_SECRET, otherwise sessions
+// stored in DynamoDB
+func NewSessionManager() SessionManager {
+	if strings.EqualFold(os.Getenv("SESSION_MODE"), "jwt") {
+		secret := secrets.Get("JWT_SECRET")
+		if len(secret) >= minJWTSecretLength {
+			return newJWTSessionManager(database.GetStore(), []byte(secret), jwtLifetime())
+		}
+		fmt.Printf("Warning: SESSION_MODE=jwt requires a JWT_SECRET of at least %d bytes, using DynamoDB sessions\n", minJWTSecretLength)
+	}
+	return &dynamoSessionManager{store: database.GetStore()}
+}
+
+// dynamoSessionManager stores sessions in DynamoDB, one lookup per request
+type dynamoSessionManager struct {
+	store database.Store
 }
 
 // CreateSession creates a new session for a user
-func (sm *SessionManager) CreateSession(ctx context.Context, username string) (string, error) {
+func (sm *dynamoSessionManager) CreateSession(ctx context.Context, username string) (string, error) {
 	sessionID := uuid.New().String()
 
 	session := &database.Session{
@@ -29,7 +60,7 @@ func (sm *SessionManager) CreateSession(ctx context.Context, username string) (s
 		Username:  username,
 	}
 
-	if err := database.CreateSession(ctx, session); err != nil {
+	if err := sm.store.CreateSession(ctx, session); err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 
@@ -37,24 +68,29 @@ func (sm *SessionManager) CreateSession(ctx context.Context, username string) (s
 }
 
 // ValidateSession validates a session and returns the username
-func (sm *SessionManager) ValidateSession(ctx context.Context, sessionID string) (string, bool) {
-	session, err := database.GetSession(ctx, sessionID)
+func (sm *dynamoSessionManager) ValidateSession(ctx context.Context, sessionID string) (string, bool) {
+	session, err := sm.store.GetSession(ctx, sessionID)
 	if err != nil || session == nil {
 		return "", false
 	}
 
 	// Check expiration
 	if time.Now().UTC().After(session.ExpiresAt) {
-		_ = database.DeleteSession(ctx, sessionID)
+		_ = sm.store.DeleteSession(ctx, sessionID)
 		return "", false
 	}
 
 	return session.Username, true
 }
 
+// RefreshSession never reissues; stored sessions last their full lifetime
+func (sm *dynamoSessionManager) RefreshSession(ctx context.Context, sessionID string) (string, bool) {
+	return "", true
+}
+
 // DeleteSession removes a session
-func (sm *SessionManager) DeleteSession(ctx context.Context, sessionID string) error {
-	return database.DeleteSession(ctx, sessionID)
+func (sm *dynamoSessionManager) DeleteSession(ctx context.Context, sessionID string) error {
+	return sm.store.DeleteSession(ctx, sessionID)
 }
 
 // GenerateCSRFToken generates a new CSRF token
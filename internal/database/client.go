@@ -4,13 +4,17 @@ import (
 	"context"
 	"os"
 
+	"dynamic-route-53-dns/internal/tracing"
+
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
 var (
-	client    *dynamodb.Client
-	tableName string
+	client         *dynamodb.Client
+	tableName      string
+	sessionTable   string
+	rateLimitTable string
 )
 
 // Init initializes the DynamoDB client
@@ -20,12 +24,24 @@ func Init(ctx context.Context) error {
 		return err
 	}
 
+	tracing.InstrumentConfig(&cfg)
 	client = dynamodb.NewFromConfig(cfg)
 	tableName = os.Getenv("DYNAMODB_TABLE")
 	if tableName == "" {
 		tableName = "dynamic-dns-table"
 	}
 
+	// Sessions and rate limits may live in their own tables, e.g. for a
+	// shorter TTL or separate billing; both default to the main table
+	sessionTable = os.Getenv("DYNAMODB_SESSION_TABLE")
+	if sessionTable == "" {
+		sessionTable = tableName
+	}
+	rateLimitTable = os.Getenv("DYNAMODB_RATELIMIT_TABLE")
+	if rateLimitTable == "" {
+		rateLimitTable = tableName
+	}
+
 	return nil
 }
 
@@ -38,3 +54,13 @@ func GetClient() *dynamodb.Client {
 func GetTableName() string {
 	return tableName
 }
+
+// SessionTableName returns the table holding sessions and revoked session IDs
+func SessionTableName() string {
+	return sessionTable
+}
+
+// RateLimitTableName returns the table holding rate limit and login attempt entries
+func RateLimitTableName() string {
+	return rateLimitTable
+}
@@ -13,17 +13,55 @@ import (
 
 // DDNSRecord represents a DDNS record in the database
 type DDNSRecord struct {
-	PK              string    `dynamodbav:"PK"`
-	SK              string    `dynamodbav:"SK"`
-	Hostname        string    `dynamodbav:"hostname"`
-	ZoneID          string    `dynamodbav:"zone_id"`
-	ZoneName        string    `dynamodbav:"zone_name"`
-	TTL             int64     `dynamodbav:"ttl"`
-	UpdateTokenHash string    `dynamodbav:"update_token_hash"`
-	CurrentIP       string    `dynamodbav:"current_ip"`
-	Enabled         bool      `dynamodbav:"enabled"`
-	LastUpdated     time.Time `dynamodbav:"last_updated"`
-	CreatedAt       time.Time `dynamodbav:"created_at"`
+	PK                string            `dynamodbav:"PK"`
+	SK                string            `dynamodbav:"SK"`
+	Hostname          string            `dynamodbav:"hostname"`
+	ZoneID            string            `dynamodbav:"zone_id"`
+	ZoneName          string            `dynamodbav:"zone_name"`
+	TTL               int64             `dynamodbav:"ttl"` // DNS TTL in seconds; 0 publishes the default TTL
+	UpdateTokenHash   string            `dynamodbav:"update_token_hash"`
+	CurrentIP         string            `dynamodbav:"current_ip"`
+	PreviousIP        string            `dynamodbav:"previous_ip,omitempty"` // IP replaced by the last change
+	PendingIP         string            `dynamodbav:"pending_ip,omitempty"`
+	Description       string            `dynamodbav:"description,omitempty"`
+	Tags              map[string]string `dynamodbav:"tags,omitempty"`
+	Enabled           bool              `dynamodbav:"enabled"`
+	Wildcard          bool              `dynamodbav:"wildcard"`
+	AllowedFamilies   string            `dynamodbav:"allowed_families,omitempty"` // "v4" or "v6" pins the address family; empty allows both
+	MX                string            `dynamodbav:"mx,omitempty"`               // "priority host" set by the DynDNS2 mx parameter
+	BackMX            bool              `dynamodbav:"backmx,omitempty"`
+	CNAME             string            `dynamodbav:"cname,omitempty"` // set by a type=CNAME update, replacing the address records
+	TXT               string            `dynamodbav:"txt,omitempty"`
+	RateLimitPerHour  int               `dynamodbav:"rate_limit_per_hour,omitempty"`
+	MinUpdateInterval int64             `dynamodbav:"min_update_interval,omitempty"` // seconds between DNS changes
+	StaticValues      []string          `dynamodbav:"static_values,omitempty"`
+	ReverseZoneID     string            `dynamodbav:"reverse_zone_id,omitempty"`
+	WebhookURL        string            `dynamodbav:"webhook_url,omitempty"`    // event webhook replacing WEBHOOK_URL for this record
+	WebhookSecret     string            `dynamodbav:"webhook_secret,omitempty"` // signs WebhookURL posts instead of WEBHOOK_SECRET
+	PausedUntil       time.Time         `dynamodbav:"paused_until"`
+	TokenExpiresAt    time.Time         `dynamodbav:"token_expires_at"`
+	IPChangedAt       time.Time         `dynamodbav:"ip_changed_at"`
+	LastUpdated       time.Time         `dynamodbav:"last_updated"`
+	CreatedAt         time.Time         `dynamodbav:"created_at"`
+}
+
+// IsPaused reports whether updates are paused for a maintenance window
+func (r *DDNSRecord) IsPaused() bool {
+	return !r.PausedUntil.IsZero() && time.Now().UTC().Before(r.PausedUntil)
+}
+
+// TokenExpiryWarning is how long before expiry a token is flagged for rotation
+const TokenExpiryWarning = 14 * 24 * time.Hour
+
+// TokenExpired reports whether the record's update token has expired.
+// Records without an expiry never expire.
+func (r *DDNSRecord) TokenExpired() bool {
+	return !r.TokenExpiresAt.IsZero() && !time.Now().UTC().Before(r.TokenExpiresAt)
+}
+
+// TokenExpiresSoon reports whether the update token expires within TokenExpiryWarning
+func (r *DDNSRecord) TokenExpiresSoon() bool {
+	return !r.TokenExpiresAt.IsZero() && time.Until(r.TokenExpiresAt) < TokenExpiryWarning
 }
 
 // UpdateLog represents an update log entry
@@ -33,7 +71,10 @@ type UpdateLog struct {
 	PreviousIP string    `dynamodbav:"previous_ip"`
 	NewIP      string    `dynamodbav:"new_ip"`
 	SourceIP   string    `dynamodbav:"source_ip"`
+	Country    string    `dynamodbav:"country,omitempty"`
 	UserAgent  string    `dynamodbav:"user_agent"`
+	Wildcard   bool      `dynamodbav:"wildcard"`
+	RecordType string    `dynamodbav:"record_type,omitempty"` // CNAME, TXT or MX for value updates, whose values sit in PreviousIP and NewIP; empty for addresses
 	Status     string    `dynamodbav:"status"`
 	TTL        int64     `dynamodbav:"ttl"`
 	Timestamp  time.Time `dynamodbav:"timestamp"`
@@ -109,6 +150,40 @@ func ListDDNSRecords(ctx context.Context) ([]DDNSRecord, error) {
 	return records, nil
 }
 
+// ListDDNSRecordsByTag retrieves the DDNS records carrying a tag, optionally
+// restricted to a tag value when value is non-empty
+func ListDDNSRecordsByTag(ctx context.Context, key, value string) ([]DDNSRecord, error) {
+	filter := "attribute_exists(#tags.#key)"
+	values := map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: "DDNS"},
+	}
+	if value != "" {
+		filter = "#tags.#key = :value"
+		values[":value"] = &types.AttributeValueMemberS{Value: value}
+	}
+
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		FilterExpression:       aws.String(filter),
+		ExpressionAttributeNames: map[string]string{
+			"#tags": "tags",
+			"#key":  key,
+		},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records by tag: %w", err)
+	}
+
+	var records []DDNSRecord
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal records: %w", err)
+	}
+
+	return records, nil
+}
+
 // UpdateDDNSRecord updates an existing DDNS record
 func UpdateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
 	record.PK = "DDNS"
@@ -131,6 +206,27 @@ func UpdateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
 	return nil
 }
 
+// UpdateTokenHash replaces the stored token hash without touching other attributes
+func UpdateTokenHash(ctx context.Context, hostname, tokenHash string) error {
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "DDNS"},
+			"SK": &types.AttributeValueMemberS{Value: hostname},
+		},
+		UpdateExpression:    aws.String("SET update_token_hash = :hash"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hash": &types.AttributeValueMemberS{Value: tokenHash},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update token hash: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteDDNSRecord deletes a DDNS record
 func DeleteDDNSRecord(ctx context.Context, hostname string) error {
 	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
@@ -196,3 +292,33 @@ func GetUpdateLogs(ctx context.Context, hostname string, limit int32) ([]UpdateL
 
 	return logs, nil
 }
+
+// ForEachUpdateLog pages through every update log for a hostname, newest
+// first, calling fn with each page. Paging stops early when fn returns false.
+func ForEachUpdateLog(ctx context.Context, hostname string, fn func([]UpdateLog) bool) error {
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("PK = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("LOG#%s", hostname)},
+			},
+			ScanIndexForward:  aws.Bool(false),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get logs: %w", err)
+		}
+
+		var logs []UpdateLog
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &logs); err != nil {
+			return fmt.Errorf("failed to unmarshal logs: %w", err)
+		}
+		if !fn(logs) || len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// idempotencyRetention is how long a create result can be replayed
+const idempotencyRetention = 10 * time.Minute
+
+// IdempotencyRecord stores the outcome of a create request keyed by its
+// idempotency key, so a retried request can recognise the original. The
+// update token is never stored here; a retry is sent to the original
+// request's token reveal, which shows it until acknowledged.
+type IdempotencyRecord struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	Completed bool   `dynamodbav:"completed"`
+	Hostname  string `dynamodbav:"hostname,omitempty"`
+	RevealID  string `dynamodbav:"reveal_id,omitempty"`
+	TTL       int64  `dynamodbav:"ttl"`
+}
+
+// idempotencySK scopes a key to the user who sent it, so one user can't
+// replay another user's request
+func idempotencySK(username, key string) string {
+	return username + "#" + key
+}
+
+// idempotencyKey returns the primary key for a user's idempotency key
+func idempotencyKey(username, key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "IDEMPOTENCY"},
+		"SK": &types.AttributeValueMemberS{Value: idempotencySK(username, key)},
+	}
+}
+
+// ClaimIdempotencyKey reserves a user's key for an in-flight request. It
+// returns false if the key is already claimed and has not expired.
+func ClaimIdempotencyKey(ctx context.Context, username, key string) (bool, error) {
+	now := time.Now()
+	item, err := attributevalue.MarshalMap(&IdempotencyRecord{
+		PK:  "IDEMPOTENCY",
+		SK:  idempotencySK(username, key),
+		TTL: now.Add(idempotencyRetention).Unix(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal idempotency key: %w", err)
+	}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK) OR #ttl < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Unix())},
+		},
+	})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetIdempotencyRecord retrieves the stored outcome for a user's key
+func GetIdempotencyRecord(ctx context.Context, username, key string) (*IdempotencyRecord, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key:       idempotencyKey(username, key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var record IdempotencyRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency key: %w", err)
+	}
+
+	return &record, nil
+}
+
+// CompleteIdempotencyKey marks a user's request as completed for hostname,
+// recording the token reveal it created, if any
+func CompleteIdempotencyKey(ctx context.Context, username, key, hostname, revealID string) error {
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName),
+		Key:              idempotencyKey(username, key),
+		UpdateExpression: aws.String("SET completed = :true, hostname = :hostname, reveal_id = :reveal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true":     &types.AttributeValueMemberBOOL{Value: true},
+			":hostname": &types.AttributeValueMemberS{Value: hostname},
+			":reveal":   &types.AttributeValueMemberS{Value: revealID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a claim so a failed request can be retried
+func ReleaseIdempotencyKey(ctx context.Context, username, key string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key:       idempotencyKey(username, key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// loginHistoryRetention is how long successful logins are kept
+const loginHistoryRetention = 90 * 24 * time.Hour
+
+// LoginRecord represents a successful login
+type LoginRecord struct {
+	PK        string    `dynamodbav:"PK"`
+	SK        string    `dynamodbav:"SK"`
+	Username  string    `dynamodbav:"username"`
+	Timestamp time.Time `dynamodbav:"timestamp"`
+	SourceIP  string    `dynamodbav:"source_ip"`
+	UserAgent string    `dynamodbav:"user_agent"`
+	TTL       int64     `dynamodbav:"ttl"`
+}
+
+// CreateLoginRecord appends a successful login to the user's history
+func CreateLoginRecord(ctx context.Context, login *LoginRecord) error {
+	login.PK = fmt.Sprintf("LOGIN_HISTORY#%s", login.Username)
+	login.Timestamp = time.Now().UTC()
+	login.SK = login.Timestamp.Format(time.RFC3339Nano)
+	login.TTL = login.Timestamp.Add(loginHistoryRetention).Unix()
+
+	item, err := attributevalue.MarshalMap(login)
+	if err != nil {
+		return fmt.Errorf("failed to marshal login record: %w", err)
+	}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create login record: %w", err)
+	}
+
+	return nil
+}
+
+// GetLoginHistory retrieves a user's most recent logins, newest first
+func GetLoginHistory(ctx context.Context, username string, limit int32) ([]LoginRecord, error) {
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("LOGIN_HISTORY#%s", username)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login history: %w", err)
+	}
+
+	var logins []LoginRecord
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &logins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login history: %w", err)
+	}
+
+	return logins, nil
+}
@@ -0,0 +1,539 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in process memory, for tests and local runs
+// without DynamoDB. It mirrors the DynamoDB functions' behavior, including
+// expiry on read, but nothing survives a restart.
+type MemoryStore struct {
+	mu            sync.Mutex
+	records       map[string]DDNSRecord
+	logs          map[string][]UpdateLog // hostname -> logs, oldest first
+	idempotency   map[string]IdempotencyRecord
+	reveals       map[string]TokenReveal
+	tokens        map[string]UpdateToken
+	sessions      map[string]Session
+	revoked       map[string]time.Time
+	rateLimits    map[string]RateLimitEntry
+	loginAttempts map[string]LoginAttempt
+	logins        map[string][]LoginRecord // username -> logins, oldest first
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:       make(map[string]DDNSRecord),
+		logs:          make(map[string][]UpdateLog),
+		idempotency:   make(map[string]IdempotencyRecord),
+		reveals:       make(map[string]TokenReveal),
+		tokens:        make(map[string]UpdateToken),
+		sessions:      make(map[string]Session),
+		revoked:       make(map[string]time.Time),
+		rateLimits:    make(map[string]RateLimitEntry),
+		loginAttempts: make(map[string]LoginAttempt),
+		logins:        make(map[string][]LoginRecord),
+	}
+}
+
+// CreateDDNSRecord creates a new DDNS record
+func (m *MemoryStore) CreateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.records[record.Hostname]; exists {
+		return fmt.Errorf("failed to create record: %s already exists", record.Hostname)
+	}
+	record.PK = "DDNS"
+	record.SK = record.Hostname
+	record.CreatedAt = time.Now().UTC()
+	record.LastUpdated = record.CreatedAt
+	m.records[record.Hostname] = copyRecord(*record)
+	return nil
+}
+
+// GetDDNSRecord retrieves a DDNS record by hostname
+func (m *MemoryStore) GetDDNSRecord(ctx context.Context, hostname string) (*DDNSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[hostname]
+	if !ok {
+		return nil, nil
+	}
+	record = copyRecord(record)
+	return &record, nil
+}
+
+// ListDDNSRecords retrieves all DDNS records, ordered by hostname
+func (m *MemoryStore) ListDDNSRecords(ctx context.Context) ([]DDNSRecord, error) {
+	return m.listRecords(func(*DDNSRecord) bool { return true }), nil
+}
+
+// ListDDNSRecordsByTag retrieves the DDNS records carrying a tag, optionally
+// restricted to a tag value when value is non-empty
+func (m *MemoryStore) ListDDNSRecordsByTag(ctx context.Context, key, value string) ([]DDNSRecord, error) {
+	return m.listRecords(func(r *DDNSRecord) bool {
+		v, ok := r.Tags[key]
+		return ok && (value == "" || v == value)
+	}), nil
+}
+
+// listRecords returns copies of the records matching keep, ordered by hostname
+func (m *MemoryStore) listRecords(keep func(*DDNSRecord) bool) []DDNSRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var records []DDNSRecord
+	for _, record := range m.records {
+		if keep(&record) {
+			records = append(records, copyRecord(record))
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Hostname < records[j].Hostname })
+	return records
+}
+
+// UpdateDDNSRecord updates an existing DDNS record
+func (m *MemoryStore) UpdateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.PK = "DDNS"
+	record.SK = record.Hostname
+	record.LastUpdated = time.Now().UTC()
+	m.records[record.Hostname] = copyRecord(*record)
+	return nil
+}
+
+// UpdateTokenHash replaces the stored token hash without touching other attributes
+func (m *MemoryStore) UpdateTokenHash(ctx context.Context, hostname, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[hostname]
+	if !ok {
+		return fmt.Errorf("failed to update token hash: %s not found", hostname)
+	}
+	record.UpdateTokenHash = tokenHash
+	m.records[hostname] = record
+	return nil
+}
+
+// DeleteDDNSRecord deletes a DDNS record
+func (m *MemoryStore) DeleteDDNSRecord(ctx context.Context, hostname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, hostname)
+	return nil
+}
+
+// copyRecord copies a record so callers can't modify the stored one
+func copyRecord(record DDNSRecord) DDNSRecord {
+	if record.Tags != nil {
+		tags := make(map[string]string, len(record.Tags))
+		for k, v := range record.Tags {
+			tags[k] = v
+		}
+		record.Tags = tags
+	}
+	record.StaticValues = append([]string(nil), record.StaticValues...)
+	return record
+}
+
+// CreateUpdateLog creates an update log entry. The caller must set log.PK to
+// "LOG#{hostname}", as for CreateUpdateLog.
+func (m *MemoryStore) CreateUpdateLog(ctx context.Context, log *UpdateLog) error {
+	hostname, ok := strings.CutPrefix(log.PK, "LOG#")
+	if !ok {
+		return fmt.Errorf("PK must be set to LOG#{hostname} by caller")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.SK = log.Timestamp.Format(time.RFC3339Nano)
+	log.TTL = time.Now().Add(30 * 24 * time.Hour).Unix()
+	m.logs[hostname] = append(m.logs[hostname], *log)
+	return nil
+}
+
+// GetUpdateLogs retrieves up to limit update logs for a hostname, newest first
+func (m *MemoryStore) GetUpdateLogs(ctx context.Context, hostname string, limit int32) ([]UpdateLog, error) {
+	logs := m.newestLogs(hostname)
+	if limit > 0 && int(limit) < len(logs) {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+// ForEachUpdateLog calls fn once with every update log for a hostname,
+// newest first
+func (m *MemoryStore) ForEachUpdateLog(ctx context.Context, hostname string, fn func([]UpdateLog) bool) error {
+	fn(m.newestLogs(hostname))
+	return nil
+}
+
+// newestLogs returns a copy of a hostname's logs, newest first
+func (m *MemoryStore) newestLogs(hostname string) []UpdateLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.logs[hostname]
+	logs := make([]UpdateLog, len(stored))
+	for i, log := range stored {
+		logs[len(stored)-1-i] = log
+	}
+	return logs
+}
+
+// ClaimIdempotencyKey reserves a user's key for an in-flight request
+func (m *MemoryStore) ClaimIdempotencyKey(ctx context.Context, username, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	sk := idempotencySK(username, key)
+	if existing, ok := m.idempotency[sk]; ok && existing.TTL >= now.Unix() {
+		return false, nil
+	}
+	m.idempotency[sk] = IdempotencyRecord{
+		PK:  "IDEMPOTENCY",
+		SK:  sk,
+		TTL: now.Add(idempotencyRetention).Unix(),
+	}
+	return true, nil
+}
+
+// GetIdempotencyRecord retrieves the stored outcome for a user's key
+func (m *MemoryStore) GetIdempotencyRecord(ctx context.Context, username, key string) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.idempotency[idempotencySK(username, key)]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyKey marks a user's request as completed for hostname
+func (m *MemoryStore) CompleteIdempotencyKey(ctx context.Context, username, key, hostname, revealID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sk := idempotencySK(username, key)
+	record := m.idempotency[sk]
+	record.PK, record.SK = "IDEMPOTENCY", sk
+	record.Completed = true
+	record.Hostname = hostname
+	record.RevealID = revealID
+	m.idempotency[sk] = record
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a claim so a failed request can be retried
+func (m *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, username, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.idempotency, idempotencySK(username, key))
+	return nil
+}
+
+// CreateTokenReveal stores a token to be shown until acknowledged
+func (m *MemoryStore) CreateTokenReveal(ctx context.Context, reveal *TokenReveal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reveal.PK = "REVEAL"
+	reveal.TTL = time.Now().Add(revealRetention).Unix()
+	m.reveals[reveal.SK] = *reveal
+	return nil
+}
+
+// GetTokenReveal retrieves a token reveal, returning nil once it has expired
+func (m *MemoryStore) GetTokenReveal(ctx context.Context, id string) (*TokenReveal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reveal, ok := m.reveals[id]
+	if !ok || reveal.TTL < time.Now().Unix() {
+		return nil, nil
+	}
+	return &reveal, nil
+}
+
+// AcknowledgeTokenReveal marks a reveal as seen and removes its token
+func (m *MemoryStore) AcknowledgeTokenReveal(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reveal, ok := m.reveals[id]
+	if !ok {
+		return nil
+	}
+	reveal.Acknowledged = true
+	reveal.Token = ""
+	m.reveals[id] = reveal
+	return nil
+}
+
+// CreateUpdateToken stores a new shared update token
+func (m *MemoryStore) CreateUpdateToken(ctx context.Context, token *UpdateToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.tokens[token.ID]; exists {
+		return fmt.Errorf("failed to create token: %s already exists", token.ID)
+	}
+	token.PK = "TOKEN"
+	token.SK = token.ID
+	token.CreatedAt = time.Now().UTC()
+	stored := *token
+	stored.Hostnames = append([]string(nil), token.Hostnames...)
+	m.tokens[token.ID] = stored
+	return nil
+}
+
+// GetUpdateToken retrieves a shared update token by ID
+func (m *MemoryStore) GetUpdateToken(ctx context.Context, id string) (*UpdateToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok {
+		return nil, nil
+	}
+	token.Hostnames = append([]string(nil), token.Hostnames...)
+	return &token, nil
+}
+
+// ListUpdateTokens retrieves all shared update tokens, ordered by ID
+func (m *MemoryStore) ListUpdateTokens(ctx context.Context) ([]UpdateToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tokens []UpdateToken
+	for _, token := range m.tokens {
+		token.Hostnames = append([]string(nil), token.Hostnames...)
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+// UpdateTokenHostnames replaces the hostnames a shared token may update
+func (m *MemoryStore) UpdateTokenHostnames(ctx context.Context, id string, hostnames []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok {
+		return fmt.Errorf("failed to update token hostnames: %s not found", id)
+	}
+	token.Hostnames = append([]string(nil), hostnames...)
+	m.tokens[id] = token
+	return nil
+}
+
+// DeleteUpdateToken deletes a shared update token
+func (m *MemoryStore) DeleteUpdateToken(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tokens, id)
+	return nil
+}
+
+// CreateSession creates a new session
+func (m *MemoryStore) CreateSession(ctx context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session.PK = "SESSION"
+	session.SK = session.SessionID
+	session.CreatedAt = time.Now().UTC()
+	session.ExpiresAt = session.CreatedAt.Add(24 * time.Hour)
+	session.TTL = session.ExpiresAt.Unix()
+	m.sessions[session.SessionID] = *session
+	return nil
+}
+
+// GetSession retrieves a session by ID, returning nil once it has expired
+func (m *MemoryStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+	if !ok || time.Now().UTC().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// DeleteSession deletes a session
+func (m *MemoryStore) DeleteSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+// RevokeSession records a signed session ID as revoked until it expires
+func (m *MemoryStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revoked[sessionID] = expiresAt
+	return nil
+}
+
+// IsSessionRevoked reports whether a signed session ID has been revoked
+func (m *MemoryStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, revoked := m.revoked[sessionID]
+	return revoked, nil
+}
+
+// IncrementRateLimit increments the rate limit counter for a key.
+// Returns the current count and whether the limit is exceeded.
+func (m *MemoryStore) IncrementRateLimit(ctx context.Context, key string, limit int, windowSeconds int64) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().Unix()
+	entry, ok := m.rateLimits[key]
+	if !ok || now > entry.WindowEnd {
+		entry = RateLimitEntry{PK: "RATELIMIT", SK: key, WindowEnd: now + windowSeconds}
+		entry.TTL = entry.WindowEnd + 60
+	}
+	entry.Count++
+	m.rateLimits[key] = entry
+	return entry.Count, entry.Count > limit, nil
+}
+
+// GetRateLimitCount returns the current rate limit count for a key
+func (m *MemoryStore) GetRateLimitCount(ctx context.Context, key string) (int, error) {
+	entry, err := m.GetRateLimitEntry(ctx, key)
+	if err != nil || entry == nil {
+		return 0, err
+	}
+	return entry.Count, nil
+}
+
+// GetRateLimitEntry loads the entry for a key, returning nil when there is
+// none or its window has ended
+func (m *MemoryStore) GetRateLimitEntry(ctx context.Context, key string) (*RateLimitEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.rateLimits[key]
+	if !ok || time.Now().Unix() > entry.WindowEnd {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// DeleteRateLimit removes the entry for a key, resetting its counter
+func (m *MemoryStore) DeleteRateLimit(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.rateLimits, key)
+	return nil
+}
+
+// RecordLoginAttempt records a login attempt and returns the updated entry,
+// applying the policy the same way as RecordLoginAttempt. A successful
+// attempt clears the entry and returns nil.
+func (m *MemoryStore) RecordLoginAttempt(ctx context.Context, username string, success bool, policy LockoutPolicy) (*LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if success {
+		delete(m.loginAttempts, username)
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	attempt := m.loginAttempts[username]
+	if attempt.IsLocked() {
+		return &attempt, nil
+	}
+
+	attempt.PK, attempt.SK = loginAttemptPK, username
+	if attempt.FirstAttempt.IsZero() || now.Sub(attempt.FirstAttempt) > policy.AttemptWindow {
+		attempt.FailedCount = 0
+		attempt.FirstAttempt = now
+	}
+	attempt.FailedCount++
+	attempt.LastAttempt = now
+	attempt.TTL = now.Add(policy.AttemptWindow + policy.LockoutDuration).Unix()
+
+	if attempt.FailedCount >= policy.MaxAttempts {
+		attempt.LockedUntil = now.Add(policy.LockoutDuration)
+		attempt.FailedCount = 0
+		attempt.FirstAttempt = time.Time{}
+	}
+
+	m.loginAttempts[username] = attempt
+	return &attempt, nil
+}
+
+// GetLoginAttempt loads the login attempt entry for a username, returning an
+// empty entry when none exists
+func (m *MemoryStore) GetLoginAttempt(ctx context.Context, username string) (*LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt := m.loginAttempts[username]
+	return &attempt, nil
+}
+
+// ClearLoginAttempts removes a username's failed login count and any lockout
+func (m *MemoryStore) ClearLoginAttempts(ctx context.Context, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.loginAttempts, username)
+	return nil
+}
+
+// CreateLoginRecord appends a successful login to the user's history
+func (m *MemoryStore) CreateLoginRecord(ctx context.Context, login *LoginRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	login.PK = fmt.Sprintf("LOGIN_HISTORY#%s", login.Username)
+	login.Timestamp = time.Now().UTC()
+	login.SK = login.Timestamp.Format(time.RFC3339Nano)
+	login.TTL = login.Timestamp.Add(loginHistoryRetention).Unix()
+	m.logins[login.Username] = append(m.logins[login.Username], *login)
+	return nil
+}
+
+// GetLoginHistory retrieves a user's most recent logins, newest first
+func (m *MemoryStore) GetLoginHistory(ctx context.Context, username string, limit int32) ([]LoginRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.logins[username]
+	logins := make([]LoginRecord, 0, len(stored))
+	for i := len(stored) - 1; i >= 0 && (limit <= 0 || len(logins) < int(limit)); i-- {
+		logins = append(logins, stored[i])
+	}
+	return logins, nil
+}
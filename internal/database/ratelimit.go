@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -20,16 +21,37 @@ type RateLimitEntry struct {
 	TTL       int64  `dynamodbav:"ttl"`
 }
 
-// LoginAttempt represents a login attempt tracking entry
+// LoginAttempt represents a login attempt tracking entry. Times are stored
+// as epoch seconds so condition expressions can compare them numerically.
 type LoginAttempt struct {
 	PK           string    `dynamodbav:"PK"`
 	SK           string    `dynamodbav:"SK"`
 	FailedCount  int       `dynamodbav:"failed_count"`
-	LastAttempt  time.Time `dynamodbav:"last_attempt"`
-	LockedUntil  time.Time `dynamodbav:"locked_until"`
+	LastAttempt  time.Time `dynamodbav:"last_attempt,unixtime"`
+	FirstAttempt time.Time `dynamodbav:"first_attempt,unixtime"`
+	LockedUntil  time.Time `dynamodbav:"locked_until,unixtime"`
 	TTL          int64     `dynamodbav:"ttl"`
 }
 
+// loginAttemptPK is the partition holding login attempt entries, keyed by username
+const loginAttemptPK = "LOGIN_ATTEMPT"
+
+// LockoutPolicy controls when repeated failed logins lock an account
+type LockoutPolicy struct {
+	MaxAttempts     int           // failures allowed before locking
+	LockoutDuration time.Duration // how long a lock lasts
+	AttemptWindow   time.Duration // failures older than this are forgotten
+	ChallengeAfter  int           // failures before a step-up challenge is required, zero to disable
+}
+
+// DefaultLockoutPolicy locks for 15 minutes after 5 failures within an hour
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxAttempts:     5,
+	LockoutDuration: 15 * time.Minute,
+	AttemptWindow:   1 * time.Hour,
+	ChallengeAfter:  3,
+}
+
 // IncrementRateLimit increments the rate limit counter for a key
 // Returns the current count and whether the limit is exceeded
 func IncrementRateLimit(ctx context.Context, key string, limit int, windowSeconds int64) (int, bool, error) {
@@ -38,7 +60,7 @@ func IncrementRateLimit(ctx context.Context, key string, limit int, windowSecond
 
 	// Try to update existing entry
 	result, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(rateLimitTable),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: "RATELIMIT"},
 			"SK": &types.AttributeValueMemberS{Value: key},
@@ -69,7 +91,7 @@ func IncrementRateLimit(ctx context.Context, key string, limit int, windowSecond
 	if now > entry.WindowEnd {
 		// Reset the counter
 		_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-			TableName: aws.String(tableName),
+			TableName: aws.String(rateLimitTable),
 			Key: map[string]types.AttributeValue{
 				"PK": &types.AttributeValueMemberS{Value: "RATELIMIT"},
 				"SK": &types.AttributeValueMemberS{Value: key},
@@ -97,7 +119,7 @@ func IncrementRateLimit(ctx context.Context, key string, limit int, windowSecond
 // GetRateLimitCount returns the current rate limit count for a key
 func GetRateLimitCount(ctx context.Context, key string) (int, error) {
 	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(rateLimitTable),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: "RATELIMIT"},
 			"SK": &types.AttributeValueMemberS{Value: key},
@@ -124,103 +146,240 @@ func GetRateLimitCount(ctx context.Context, key string) (int, error) {
 	return entry.Count, nil
 }
 
-// RecordLoginAttempt records a login attempt and returns whether the account is locked
-func RecordLoginAttempt(ctx context.Context, username string, success bool) (bool, time.Time, error) {
-	now := time.Now().UTC()
+// GetRateLimitEntry loads the entry for a key, returning nil when there is
+// none or its window has ended
+func GetRateLimitEntry(ctx context.Context, key string) (*RateLimitEntry, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(rateLimitTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "RATELIMIT"},
+			"SK": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit: %w", err)
+	}
 
-	if success {
-		// Clear failed attempts on success
-		_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-			TableName: aws.String(tableName),
-			Key: map[string]types.AttributeValue{
-				"PK": &types.AttributeValueMemberS{Value: "LOGIN_ATTEMPT"},
-				"SK": &types.AttributeValueMemberS{Value: username},
-			},
-		})
-		return false, time.Time{}, err
+	if result.Item == nil {
+		return nil, nil
 	}
 
-	// Get current attempts
-	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
+	var entry RateLimitEntry
+	if err := attributevalue.UnmarshalMap(result.Item, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rate limit: %w", err)
+	}
+
+	if time.Now().Unix() > entry.WindowEnd {
+		return nil, nil
+	}
+
+	return &entry, nil
+}
+
+// DeleteRateLimit removes the entry for a key, resetting its counter
+func DeleteRateLimit(ctx context.Context, key string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(rateLimitTable),
 		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: "LOGIN_ATTEMPT"},
+			"PK": &types.AttributeValueMemberS{Value: "RATELIMIT"},
+			"SK": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete rate limit: %w", err)
+	}
+
+	return nil
+}
+
+// ClearLoginAttempts removes a username's failed login count and any lockout
+func ClearLoginAttempts(ctx context.Context, username string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(rateLimitTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: loginAttemptPK},
 			"SK": &types.AttributeValueMemberS{Value: username},
 		},
 	})
 	if err != nil {
-		return false, time.Time{}, fmt.Errorf("failed to get login attempts: %w", err)
+		return fmt.Errorf("failed to clear login attempts: %w", err)
 	}
 
-	var attempt LoginAttempt
-	if result.Item != nil {
-		if err := attributevalue.UnmarshalMap(result.Item, &attempt); err != nil {
-			return false, time.Time{}, fmt.Errorf("failed to unmarshal login attempt: %w", err)
-		}
+	return nil
+}
+
+// IsLocked reports whether the attempt entry currently holds a lockout
+func (a *LoginAttempt) IsLocked() bool {
+	return !a.LockedUntil.IsZero() && time.Now().UTC().Before(a.LockedUntil)
+}
+
+// NeedsChallenge reports whether failures have crossed the policy's soft
+// threshold, so further logins must solve a challenge
+func (a *LoginAttempt) NeedsChallenge(policy LockoutPolicy) bool {
+	if policy.ChallengeAfter <= 0 || a.IsLocked() {
+		return false
 	}
+	return a.FailedCount >= policy.ChallengeAfter && time.Since(a.FirstAttempt) <= policy.AttemptWindow
+}
 
-	// Check if currently locked
-	if !attempt.LockedUntil.IsZero() && now.Before(attempt.LockedUntil) {
-		return true, attempt.LockedUntil, nil
+// AttemptsRemaining returns how many more failures the policy allows before lockout
+func (a *LoginAttempt) AttemptsRemaining(policy LockoutPolicy) int {
+	if a.IsLocked() {
+		return 0
 	}
+	return policy.MaxAttempts - a.FailedCount
+}
 
-	// Increment failed count
-	attempt.PK = "LOGIN_ATTEMPT"
-	attempt.SK = username
-	attempt.FailedCount++
-	attempt.LastAttempt = now
+// RecordLoginAttempt records a login attempt and returns the updated entry.
+// A successful attempt clears the entry and returns nil.
+func RecordLoginAttempt(ctx context.Context, username string, success bool, policy LockoutPolicy) (*LoginAttempt, error) {
+	now := time.Now().UTC()
 
-	// Lock after 5 failed attempts
-	if attempt.FailedCount >= 5 {
-		attempt.LockedUntil = now.Add(15 * time.Minute)
-		attempt.FailedCount = 0 // Reset count after locking
+	if success {
+		// Clear failed attempts on success
+		return nil, ClearLoginAttempts(ctx, username)
 	}
 
-	// Set TTL to 1 hour from now
-	attempt.TTL = now.Add(1 * time.Hour).Unix()
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: loginAttemptPK},
+		"SK": &types.AttributeValueMemberS{Value: username},
+	}
+	nowValue := &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Unix())}
+
+	// Failures are only counted while no lockout is in force. Times are
+	// epoch seconds, so they compare numerically; entries written before
+	// that stored RFC 3339 strings, which never compare to a number and are
+	// overwritten instead.
+	const unlocked = "(attribute_not_exists(locked_until) OR locked_until < :now OR attribute_type(locked_until, :string))"
+	values := map[string]types.AttributeValue{
+		":one":    &types.AttributeValueMemberN{Value: "1"},
+		":now":    nowValue,
+		":string": &types.AttributeValueMemberS{Value: "S"},
+		":ttl":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Add(policy.AttemptWindow+policy.LockoutDuration).Unix())},
+	}
 
-	item, err := attributevalue.MarshalMap(attempt)
+	// Start a new window once the earliest counted failure has aged out.
+	// The condition makes the reset atomic: once one caller restarts the
+	// window, concurrent callers see a recent first_attempt and count on
+	// top of it instead of wiping it.
+	resetValues := map[string]types.AttributeValue{
+		":windowStart": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Add(-policy.AttemptWindow).Unix())},
+	}
+	for k, v := range values {
+		resetValues[k] = v
+	}
+	result, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(rateLimitTable),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET failed_count = :one, first_attempt = :now, last_attempt = :now, #ttl = :ttl"),
+		ConditionExpression: aws.String("(first_attempt < :windowStart OR attribute_type(first_attempt, :string)) AND " + unlocked),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: resetValues,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if isConditionFailed(err) {
+		// The window is still open (or there is none yet): count the failure
+		values[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+		result, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String(rateLimitTable),
+			Key:                 key,
+			UpdateExpression:    aws.String("SET failed_count = if_not_exists(failed_count, :zero) + :one, first_attempt = if_not_exists(first_attempt, :now), last_attempt = :now, #ttl = :ttl"),
+			ConditionExpression: aws.String(unlocked),
+			ExpressionAttributeNames: map[string]string{
+				"#ttl": "ttl",
+			},
+			ExpressionAttributeValues: values,
+			ReturnValues:              types.ReturnValueAllNew,
+		})
+		if isConditionFailed(err) {
+			// Already locked
+			return GetLoginAttempt(ctx, username)
+		}
+	}
 	if err != nil {
-		return false, time.Time{}, fmt.Errorf("failed to marshal login attempt: %w", err)
+		return nil, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	var attempt LoginAttempt
+	if err := attributevalue.UnmarshalMap(result.Attributes, &attempt); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login attempt: %w", err)
+	}
+
+	if attempt.FailedCount < policy.MaxAttempts {
+		return &attempt, nil
 	}
 
-	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item:      item,
+	// Lock after too many failed attempts. The condition lets only one of
+	// several concurrent callers apply the lock; the rest read it back.
+	lockedUntil := &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Add(policy.LockoutDuration).Unix())}
+	result, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(rateLimitTable),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET locked_until = :lockedUntil, failed_count = :zero REMOVE first_attempt"),
+		ConditionExpression: aws.String("failed_count >= :max"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":lockedUntil": lockedUntil,
+			":zero":        &types.AttributeValueMemberN{Value: "0"},
+			":max":         &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", policy.MaxAttempts)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
 	})
+	if isConditionFailed(err) {
+		return GetLoginAttempt(ctx, username)
+	}
 	if err != nil {
-		return false, time.Time{}, fmt.Errorf("failed to record login attempt: %w", err)
+		return nil, fmt.Errorf("failed to lock login: %w", err)
 	}
 
-	isLocked := !attempt.LockedUntil.IsZero() && now.Before(attempt.LockedUntil)
-	return isLocked, attempt.LockedUntil, nil
+	attempt = LoginAttempt{}
+	if err := attributevalue.UnmarshalMap(result.Attributes, &attempt); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login attempt: %w", err)
+	}
+	return &attempt, nil
+}
+
+// isConditionFailed reports whether err is a failed DynamoDB condition check
+func isConditionFailed(err error) bool {
+	var condErr *types.ConditionalCheckFailedException
+	return errors.As(err, &condErr)
 }
 
 // IsAccountLocked checks if an account is currently locked
 func IsAccountLocked(ctx context.Context, username string) (bool, time.Time, error) {
+	attempt, err := GetLoginAttempt(ctx, username)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if attempt.IsLocked() {
+		return true, attempt.LockedUntil, nil
+	}
+
+	return false, time.Time{}, nil
+}
+
+// GetLoginAttempt loads the login attempt entry for a username, returning an
+// empty entry when none exists
+func GetLoginAttempt(ctx context.Context, username string) (*LoginAttempt, error) {
 	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(rateLimitTable),
 		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: "LOGIN_ATTEMPT"},
+			"PK": &types.AttributeValueMemberS{Value: loginAttemptPK},
 			"SK": &types.AttributeValueMemberS{Value: username},
 		},
 	})
 	if err != nil {
-		return false, time.Time{}, fmt.Errorf("failed to get login attempts: %w", err)
-	}
-
-	if result.Item == nil {
-		return false, time.Time{}, nil
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
 	}
 
 	var attempt LoginAttempt
-	if err := attributevalue.UnmarshalMap(result.Item, &attempt); err != nil {
-		return false, time.Time{}, fmt.Errorf("failed to unmarshal login attempt: %w", err)
-	}
-
-	if !attempt.LockedUntil.IsZero() && time.Now().UTC().Before(attempt.LockedUntil) {
-		return true, attempt.LockedUntil, nil
+	if result.Item != nil {
+		if err := attributevalue.UnmarshalMap(result.Item, &attempt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal login attempt: %w", err)
+		}
 	}
 
-	return false, time.Time{}, nil
+	return &attempt, nil
 }
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// revealRetention is how long a new update token can be shown again before
+// it is acknowledged
+const revealRetention = 15 * time.Minute
+
+// TokenReveal holds a newly issued update token until the user confirms
+// they have saved it. Acknowledging it drops the token but keeps the entry,
+// so the page can say the token was already revealed.
+type TokenReveal struct {
+	PK           string `dynamodbav:"PK"`
+	SK           string `dynamodbav:"SK"`
+	Hostname     string `dynamodbav:"hostname"`
+	Username     string `dynamodbav:"username"`
+	Token        string `dynamodbav:"token,omitempty"`
+	Regenerated  bool   `dynamodbav:"regenerated"`
+	Acknowledged bool   `dynamodbav:"acknowledged"`
+	TTL          int64  `dynamodbav:"ttl"`
+}
+
+// tokenRevealKey returns the primary key for a token reveal
+func tokenRevealKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "REVEAL"},
+		"SK": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// CreateTokenReveal stores a token to be shown until acknowledged, keyed by
+// the reveal's SK
+func CreateTokenReveal(ctx context.Context, reveal *TokenReveal) error {
+	reveal.PK = "REVEAL"
+	reveal.TTL = time.Now().Add(revealRetention).Unix()
+
+	item, err := attributevalue.MarshalMap(reveal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token reveal: %w", err)
+	}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create token reveal: %w", err)
+	}
+
+	return nil
+}
+
+// GetTokenReveal retrieves a token reveal, returning nil once it has expired
+func GetTokenReveal(ctx context.Context, id string) (*TokenReveal, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key:       tokenRevealKey(id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token reveal: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var reveal TokenReveal
+	if err := attributevalue.UnmarshalMap(result.Item, &reveal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token reveal: %w", err)
+	}
+
+	// DynamoDB TTL deletion lags, so expiry is enforced on read
+	if reveal.TTL < time.Now().Unix() {
+		return nil, nil
+	}
+
+	return &reveal, nil
+}
+
+// AcknowledgeTokenReveal marks a reveal as seen and removes its token
+func AcknowledgeTokenReveal(ctx context.Context, id string) error {
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 tokenRevealKey(id),
+		UpdateExpression:    aws.String("SET acknowledged = :true REMOVE #token"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeNames: map[string]string{
+			"#token": "token",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+	if isConditionFailed(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge token reveal: %w", err)
+	}
+
+	return nil
+}
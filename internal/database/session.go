@@ -36,7 +36,7 @@ func CreateSession(ctx context.Context, session *Session) error {
 	}
 
 	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(sessionTable),
 		Item:      item,
 	})
 	if err != nil {
@@ -49,7 +49,7 @@ func CreateSession(ctx context.Context, session *Session) error {
 // GetSession retrieves a session by ID
 func GetSession(ctx context.Context, sessionID string) (*Session, error) {
 	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(sessionTable),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: "SESSION"},
 			"SK": &types.AttributeValueMemberS{Value: sessionID},
@@ -79,7 +79,7 @@ func GetSession(ctx context.Context, sessionID string) (*Session, error) {
 // DeleteSession deletes a session
 func DeleteSession(ctx context.Context, sessionID string) error {
 	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(sessionTable),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: "SESSION"},
 			"SK": &types.AttributeValueMemberS{Value: sessionID},
@@ -91,3 +91,39 @@ func DeleteSession(ctx context.Context, sessionID string) error {
 
 	return nil
 }
+
+// revokedSessionPK is the partition holding revoked signed session IDs
+const revokedSessionPK = "SESSION_REVOKED"
+
+// RevokeSession records a signed session ID as revoked until it expires
+func RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(sessionTable),
+		Item: map[string]types.AttributeValue{
+			"PK":  &types.AttributeValueMemberS{Value: revokedSessionPK},
+			"SK":  &types.AttributeValueMemberS{Value: sessionID},
+			"ttl": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", expiresAt.Unix())},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// IsSessionRevoked reports whether a signed session ID has been revoked
+func IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(sessionTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: revokedSessionPK},
+			"SK": &types.AttributeValueMemberS{Value: sessionID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check session revocation: %w", err)
+	}
+
+	return result.Item != nil, nil
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the persistence the services need. DynamoStore implements it with
+// the DynamoDB table and MemoryStore in process memory; other backends (or
+// fakes in tests) can be swapped in with SetStore before the services are
+// created.
+type Store interface {
+	// DDNS records
+	CreateDDNSRecord(ctx context.Context, record *DDNSRecord) error
+	GetDDNSRecord(ctx context.Context, hostname string) (*DDNSRecord, error)
+	ListDDNSRecords(ctx context.Context) ([]DDNSRecord, error)
+	ListDDNSRecordsByTag(ctx context.Context, key, value string) ([]DDNSRecord, error)
+	UpdateDDNSRecord(ctx context.Context, record *DDNSRecord) error
+	UpdateTokenHash(ctx context.Context, hostname, tokenHash string) error
+	DeleteDDNSRecord(ctx context.Context, hostname string) error
+
+	// Update logs
+	CreateUpdateLog(ctx context.Context, log *UpdateLog) error
+	GetUpdateLogs(ctx context.Context, hostname string, limit int32) ([]UpdateLog, error)
+	ForEachUpdateLog(ctx context.Context, hostname string, fn func([]UpdateLog) bool) error
+
+	// Idempotency keys for record creation
+	ClaimIdempotencyKey(ctx context.Context, username, key string) (bool, error)
+	GetIdempotencyRecord(ctx context.Context, username, key string) (*IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, username, key, hostname, revealID string) error
+	ReleaseIdempotencyKey(ctx context.Context, username, key string) error
+
+	// One-time reveals of new update tokens
+	CreateTokenReveal(ctx context.Context, reveal *TokenReveal) error
+	GetTokenReveal(ctx context.Context, id string) (*TokenReveal, error)
+	AcknowledgeTokenReveal(ctx context.Context, id string) error
+
+	// Shared update tokens
+	CreateUpdateToken(ctx context.Context, token *UpdateToken) error
+	GetUpdateToken(ctx context.Context, id string) (*UpdateToken, error)
+	ListUpdateTokens(ctx context.Context) ([]UpdateToken, error)
+	UpdateTokenHostnames(ctx context.Context, id string, hostnames []string) error
+	DeleteUpdateToken(ctx context.Context, id string) error
+
+	// Sessions
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	DeleteSession(ctx context.Context, sessionID string) error
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+
+	// Rate limits
+	IncrementRateLimit(ctx context.Context, key string, limit int, windowSeconds int64) (int, bool, error)
+	GetRateLimitCount(ctx context.Context, key string) (int, error)
+	GetRateLimitEntry(ctx context.Context, key string) (*RateLimitEntry, error)
+	DeleteRateLimit(ctx context.Context, key string) error
+
+	// Login attempts and history
+	RecordLoginAttempt(ctx context.Context, username string, success bool, policy LockoutPolicy) (*LoginAttempt, error)
+	GetLoginAttempt(ctx context.Context, username string) (*LoginAttempt, error)
+	ClearLoginAttempts(ctx context.Context, username string) error
+	CreateLoginRecord(ctx context.Context, login *LoginRecord) error
+	GetLoginHistory(ctx context.Context, username string, limit int32) ([]LoginRecord, error)
+}
+
+// store is the Store handed to services, DynamoDB unless replaced
+var store Store = DynamoStore{}
+
+// GetStore returns the configured Store
+func GetStore() Store {
+	return store
+}
+
+// SetStore replaces the Store returned by GetStore
+func SetStore(s Store) {
+	store = s
+}
+
+// DynamoStore is the Store backed by the DynamoDB client set up by Init
+type DynamoStore struct{}
+
+var _ Store = DynamoStore{}
+
+// The DynamoStore methods delegate to the package-level DynamoDB functions
+
+func (DynamoStore) CreateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
+	return CreateDDNSRecord(ctx, record)
+}
+
+func (DynamoStore) GetDDNSRecord(ctx context.Context, hostname string) (*DDNSRecord, error) {
+	return GetDDNSRecord(ctx, hostname)
+}
+
+func (DynamoStore) ListDDNSRecords(ctx context.Context) ([]DDNSRecord, error) {
+	return ListDDNSRecords(ctx)
+}
+
+func (DynamoStore) ListDDNSRecordsByTag(ctx context.Context, key, value string) ([]DDNSRecord, error) {
+	return ListDDNSRecordsByTag(ctx, key, value)
+}
+
+func (DynamoStore) UpdateDDNSRecord(ctx context.Context, record *DDNSRecord) error {
+	return UpdateDDNSRecord(ctx, record)
+}
+
+func (DynamoStore) UpdateTokenHash(ctx context.Context, hostname, tokenHash string) error {
+	return UpdateTokenHash(ctx, hostname, tokenHash)
+}
+
+func (DynamoStore) DeleteDDNSRecord(ctx context.Context, hostname string) error {
+	return DeleteDDNSRecord(ctx, hostname)
+}
+
+func (DynamoStore) CreateUpdateLog(ctx context.Context, log *UpdateLog) error {
+	return CreateUpdateLog(ctx, log)
+}
+
+func (DynamoStore) GetUpdateLogs(ctx context.Context, hostname string, limit int32) ([]UpdateLog, error) {
+	return GetUpdateLogs(ctx, hostname, limit)
+}
+
+func (DynamoStore) ForEachUpdateLog(ctx context.Context, hostname string, fn func([]UpdateLog) bool) error {
+	return ForEachUpdateLog(ctx, hostname, fn)
+}
+
+func (DynamoStore) ClaimIdempotencyKey(ctx context.Context, username, key string) (bool, error) {
+	return ClaimIdempotencyKey(ctx, username, key)
+}
+
+func (DynamoStore) GetIdempotencyRecord(ctx context.Context, username, key string) (*IdempotencyRecord, error) {
+	return GetIdempotencyRecord(ctx, username, key)
+}
+
+func (DynamoStore) CompleteIdempotencyKey(ctx context.Context, username, key, hostname, revealID string) error {
+	return CompleteIdempotencyKey(ctx, username, key, hostname, revealID)
+}
+
+func (DynamoStore) ReleaseIdempotencyKey(ctx context.Context, username, key string) error {
+	return ReleaseIdempotencyKey(ctx, username, key)
+}
+
+func (DynamoStore) CreateTokenReveal(ctx context.Context, reveal *TokenReveal) error {
+	return CreateTokenReveal(ctx, reveal)
+}
+
+func (DynamoStore) GetTokenReveal(ctx context.Context, id string) (*TokenReveal, error) {
+	return GetTokenReveal(ctx, id)
+}
+
+func (DynamoStore) AcknowledgeTokenReveal(ctx context.Context, id string) error {
+	return AcknowledgeTokenReveal(ctx, id)
+}
+
+func (DynamoStore) CreateUpdateToken(ctx context.Context, token *UpdateToken) error {
+	return CreateUpdateToken(ctx, token)
+}
+
+func (DynamoStore) GetUpdateToken(ctx context.Context, id string) (*UpdateToken, error) {
+	return GetUpdateToken(ctx, id)
+}
+
+func (DynamoStore) ListUpdateTokens(ctx context.Context) ([]UpdateToken, error) {
+	return ListUpdateTokens(ctx)
+}
+
+func (DynamoStore) UpdateTokenHostnames(ctx context.Context, id string, hostnames []string) error {
+	return UpdateTokenHostnames(ctx, id, hostnames)
+}
+
+func (DynamoStore) DeleteUpdateToken(ctx context.Context, id string) error {
+	return DeleteUpdateToken(ctx, id)
+}
+
+func (DynamoStore) CreateSession(ctx context.Context, session *Session) error {
+	return CreateSession(ctx, session)
+}
+
+func (DynamoStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	return GetSession(ctx, sessionID)
+}
+
+func (DynamoStore) DeleteSession(ctx context.Context, sessionID string) error {
+	return DeleteSession(ctx, sessionID)
+}
+
+func (DynamoStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	return RevokeSession(ctx, sessionID, expiresAt)
+}
+
+func (DynamoStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return IsSessionRevoked(ctx, sessionID)
+}
+
+func (DynamoStore) IncrementRateLimit(ctx context.Context, key string, limit int, windowSeconds int64) (int, bool, error) {
+	return IncrementRateLimit(ctx, key, limit, windowSeconds)
+}
+
+func (DynamoStore) GetRateLimitCount(ctx context.Context, key string) (int, error) {
+	return GetRateLimitCount(ctx, key)
+}
+
+func (DynamoStore) GetRateLimitEntry(ctx context.Context, key string) (*RateLimitEntry, error) {
+	return GetRateLimitEntry(ctx, key)
+}
+
+func (DynamoStore) DeleteRateLimit(ctx context.Context, key string) error {
+	return DeleteRateLimit(ctx, key)
+}
+
+func (DynamoStore) RecordLoginAttempt(ctx context.Context, username string, success bool, policy LockoutPolicy) (*LoginAttempt, error) {
+	return RecordLoginAttempt(ctx, username, success, policy)
+}
+
+func (DynamoStore) GetLoginAttempt(ctx context.Context, username string) (*LoginAttempt, error) {
+	return GetLoginAttempt(ctx, username)
+}
+
+func (DynamoStore) ClearLoginAttempts(ctx context.Context, username string) error {
+	return ClearLoginAttempts(ctx, username)
+}
+
+func (DynamoStore) CreateLoginRecord(ctx context.Context, login *LoginRecord) error {
+	return CreateLoginRecord(ctx, login)
+}
+
+func (DynamoStore) GetLoginHistory(ctx context.Context, username string, limit int32) ([]LoginRecord, error) {
+	return GetLoginHistory(ctx, username, limit)
+}
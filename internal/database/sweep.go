@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// sweepBatchSize is the most deletes DynamoDB accepts in one BatchWriteItem
+const sweepBatchSize = 25
+
+// sweepMaxRetries bounds how often unprocessed deletes are resubmitted
+const sweepMaxRetries = 5
+
+// ttlHorizon mirrors DynamoDB TTL, which ignores timestamps more than five
+// years in the past. DDNS records store their DNS TTL (e.g. 60) in the same
+// "ttl" attribute and must never match.
+const ttlHorizon = 5 * 365 * 24 * time.Hour
+
+// SweepExpired deletes items whose ttl attribute is in the past, for tables
+// where DynamoDB TTL is disabled or lagging. The main, session and rate limit
+// tables are each swept once. It is safe to run repeatedly; items already
+// deleted are simply not found again. Returns how many items were deleted.
+func SweepExpired(ctx context.Context, now time.Time) (int, error) {
+	var deleted int
+	swept := make(map[string]bool)
+	for _, table := range []string{tableName, sessionTable, rateLimitTable} {
+		if swept[table] {
+			continue
+		}
+		swept[table] = true
+
+		count, err := sweepTable(ctx, table, now)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// sweepTable deletes the expired items in one table
+func sweepTable(ctx context.Context, table string, now time.Time) (int, error) {
+	var deleted int
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:            aws.String(table),
+			FilterExpression:     aws.String("#ttl < :now AND #ttl > :horizon AND PK <> :ddns"),
+			ProjectionExpression: aws.String("PK, SK"),
+			ExpressionAttributeNames: map[string]string{
+				"#ttl": "ttl",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Unix())},
+				":horizon": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Add(-ttlHorizon).Unix())},
+				":ddns":    &types.AttributeValueMemberS{Value: "DDNS"},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan for expired items: %w", err)
+		}
+
+		for i := 0; i < len(result.Items); i += sweepBatchSize {
+			end := i + sweepBatchSize
+			if end > len(result.Items) {
+				end = len(result.Items)
+			}
+			if err := deleteBatch(ctx, table, result.Items[i:end]); err != nil {
+				return deleted, err
+			}
+			deleted += end - i
+		}
+
+		if result.LastEvaluatedKey == nil {
+			return deleted, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// deleteBatch deletes up to sweepBatchSize items by key, resubmitting any
+// that DynamoDB leaves unprocessed
+func deleteBatch(ctx context.Context, table string, keys []map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		requests = append(requests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: key},
+		})
+	}
+
+	pending := map[string][]types.WriteRequest{table: requests}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > sweepMaxRetries {
+			return fmt.Errorf("failed to delete %d expired items after %d retries", len(pending[table]), sweepMaxRetries)
+		}
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+
+		result, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: pending,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete expired items: %w", err)
+		}
+		pending = result.UnprocessedItems
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// UpdateToken is a shared update token allowed to update several hostnames
+type UpdateToken struct {
+	PK        string    `dynamodbav:"PK"`
+	SK        string    `dynamodbav:"SK"`
+	ID        string    `dynamodbav:"id"`
+	Name      string    `dynamodbav:"name"`
+	TokenHash string    `dynamodbav:"token_hash"`
+	Hostnames []string  `dynamodbav:"hostnames"`
+	CreatedAt time.Time `dynamodbav:"created_at"`
+}
+
+// CanUpdate reports whether the token may update hostname
+func (t *UpdateToken) CanUpdate(hostname string) bool {
+	for _, h := range t.Hostnames {
+		if h == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateUpdateToken stores a new shared update token
+func CreateUpdateToken(ctx context.Context, token *UpdateToken) error {
+	token.PK = "TOKEN"
+	token.SK = token.ID
+	token.CreatedAt = time.Now().UTC()
+
+	item, err := attributevalue.MarshalMap(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return nil
+}
+
+// GetUpdateToken retrieves a shared update token by ID
+func GetUpdateToken(ctx context.Context, id string) (*UpdateToken, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOKEN"},
+			"SK": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var token UpdateToken
+	if err := attributevalue.UnmarshalMap(result.Item, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListUpdateTokens retrieves all shared update tokens
+func ListUpdateTokens(ctx context.Context) ([]UpdateToken, error) {
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "TOKEN"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	var tokens []UpdateToken
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// UpdateTokenHostnames replaces the hostnames a shared token may update
+func UpdateTokenHostnames(ctx context.Context, id string, hostnames []string) error {
+	hostnameList, err := attributevalue.Marshal(hostnames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hostnames: %w", err)
+	}
+
+	_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOKEN"},
+			"SK": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET hostnames = :hostnames"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hostnames": hostnameList,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update token hostnames: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUpdateToken deletes a shared update token
+func DeleteUpdateToken(ctx context.Context, id string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOKEN"},
+			"SK": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
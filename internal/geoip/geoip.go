@@ -0,0 +1,68 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var (
+	reader   *maxminddb.Reader
+	loadErr  error
+	loadOnce sync.Once
+)
+
+// countryRecord is the subset of a GeoIP2/GeoLite2 Country entry we read
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// DBPath returns the MaxMind database path from GEOIP_DB_PATH
+func DBPath() string {
+	return os.Getenv("GEOIP_DB_PATH")
+}
+
+// Enabled reports whether a GeoIP database is configured
+func Enabled() bool {
+	return DBPath() != ""
+}
+
+// load opens the database once per process
+func load() error {
+	loadOnce.Do(func() {
+		if !Enabled() {
+			loadErr = fmt.Errorf("GEOIP_DB_PATH not set")
+			return
+		}
+		reader, loadErr = maxminddb.Open(DBPath())
+		if loadErr != nil {
+			fmt.Printf("Warning: Failed to open GeoIP database: %v\n", loadErr)
+		}
+	})
+	return loadErr
+}
+
+// Country returns the ISO 3166-1 alpha-2 country code for an IP, or an
+// empty string when it is not in the database
+func Country(ip string) (string, error) {
+	if err := load(); err != nil {
+		return "", err
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	var record countryRecord
+	if err := reader.Lookup(parsed, &record); err != nil {
+		return "", fmt.Errorf("geoip lookup failed: %w", err)
+	}
+
+	return record.Country.ISOCode, nil
+}
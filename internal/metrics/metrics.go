@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pushTimeout bounds a Pushgateway push so it barely delays the response
+const pushTimeout = 500 * time.Millisecond
+
+// staleGroupAge is how long an instance's group may go without a push before
+// another instance deletes it. Lambda environments are usually reclaimed
+// well within it; one that was only idle pushes its totals again next time.
+const staleGroupAge = time.Hour
+
+// pruneInterval is how often a process looks for stale groups
+const pruneInterval = 10 * time.Minute
+
+// counter is one labelled time series
+type counter struct {
+	name   string
+	labels string // rendered {k="v",...}, empty without labels
+	value  float64
+}
+
+var (
+	mu       sync.Mutex
+	counters = map[string]*counter{}
+	dirty    bool // counters changed since the last push
+
+	lastPrune time.Time // when stale groups were last pruned
+)
+
+// Inc adds one to the counter name with the given label name/value pairs
+func Inc(name string, labelPairs ...string) {
+	labels := renderLabels(labelPairs)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := name + labels
+	c, ok := counters[key]
+	if !ok {
+		c = &counter{name: name, labels: labels}
+		counters[key] = c
+	}
+	c.value++
+	dirty = true
+}
+
+// renderLabels formats label pairs in the Prometheus text format
+func renderLabels(pairs []string) string {
+	if len(pairs) < 2 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WriteText writes all counters in the Prometheus text exposition format
+func WriteText(w io.Writer) error {
+	mu.Lock()
+	series := make([]counter, 0, len(counters))
+	for _, c := range counters {
+		series = append(series, *c)
+	}
+	mu.Unlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].name != series[j].name {
+			return series[i].name < series[j].name
+		}
+		return series[i].labels < series[j].labels
+	})
+
+	var lastName string
+	for _, c := range series {
+		if c.name != lastName {
+			if _, err := fmt.Fprintf(w, "# TYPE %s counter\n", c.name); err != nil {
+				return err
+			}
+			lastName = c.name
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %g\n", c.name, c.labels, c.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushgatewayURL returns PROMETHEUS_PUSHGATEWAY_URL, empty when pushing is disabled
+func PushgatewayURL() string {
+	return strings.TrimSuffix(os.Getenv("PROMETHEUS_PUSHGATEWAY_URL"), "/")
+}
+
+// jobName returns the Pushgateway job label: the Lambda function name
+func jobName() string {
+	if job := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); job != "" {
+		return job
+	}
+	return "dynamic-route-53-dns"
+}
+
+// instanceName returns the Pushgateway instance label for this process
+func instanceName() string {
+	if instance := os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME"); instance != "" {
+		return instance
+	}
+	instance, _ := os.Hostname()
+	return instance
+}
+
+// pushURL returns the Pushgateway group for this process. Counters are
+// cumulative per Lambda execution environment, so each environment pushes
+// to its own instance and replaces only its own series. Groups of
+// environments that are gone are removed by Delete on shutdown, or by
+// pruneStale once they stop pushing.
+func pushURL(base string) string {
+	return groupURL(base, jobName(), instanceName())
+}
+
+// groupURL returns the Pushgateway URL of a job and instance group
+func groupURL(base, job, instance string) string {
+	// Base64 label values may contain the slashes log stream names have
+	return fmt.Sprintf("%s/metrics/job@base64/%s/instance@base64/%s", base,
+		base64.RawURLEncoding.EncodeToString([]byte(job)),
+		base64.RawURLEncoding.EncodeToString([]byte(instance)))
+}
+
+// Push sends the counters to the Pushgateway when one is configured and
+// anything changed since the last push. It is best-effort: failures are
+// logged and the counters are pushed again next time.
+func Push(ctx context.Context) {
+	base := PushgatewayURL()
+	if base == "" {
+		return
+	}
+
+	mu.Lock()
+	changed := dirty
+	dirty = false
+	mu.Unlock()
+	if !changed {
+		return
+	}
+
+	var body bytes.Buffer
+	if err := WriteText(&body); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	if err := put(ctx, pushURL(base), &body); err != nil {
+		fmt.Printf("Warning: Failed to push metrics: %v\n", err)
+		mu.Lock()
+		dirty = true
+		mu.Unlock()
+		return
+	}
+
+	mu.Lock()
+	due := time.Since(lastPrune) >= pruneInterval
+	if due {
+		lastPrune = time.Now()
+	}
+	mu.Unlock()
+	if due {
+		if err := pruneStale(ctx, base); err != nil {
+			fmt.Printf("Warning: Failed to prune stale metrics groups: %v\n", err)
+		}
+	}
+}
+
+// Delete removes this process's group from the Pushgateway, so a shut down
+// environment's counters are not kept forever. Call it on SIGTERM.
+func Delete(ctx context.Context) {
+	base := PushgatewayURL()
+	if base == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	if err := deleteGroup(ctx, pushURL(base)); err != nil {
+		fmt.Printf("Warning: Failed to delete metrics group: %v\n", err)
+	}
+}
+
+// groupStatus is the part of a Pushgateway /api/v1/metrics group used to
+// find stale groups
+type groupStatus struct {
+	Labels   map[string]string `json:"labels"`
+	PushTime struct {
+		Metrics []struct {
+			Value string `json:"value"`
+		} `json:"metrics"`
+	} `json:"push_time_seconds"`
+}
+
+// pruneStale deletes this job's groups, other than this process's, that
+// have not been pushed to for staleGroupAge, going by push_time_seconds
+func pruneStale(ctx context.Context, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/v1/metrics", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned status %d", resp.StatusCode)
+	}
+
+	var status struct {
+		Data []groupStatus `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to decode pushgateway groups: %w", err)
+	}
+
+	job, self := jobName(), instanceName()
+	cutoff := float64(time.Now().Add(-staleGroupAge).Unix())
+	for _, group := range status.Data {
+		instance := group.Labels["instance"]
+		if group.Labels["job"] != job || instance == self || len(group.PushTime.Metrics) == 0 {
+			continue
+		}
+		pushed, err := strconv.ParseFloat(group.PushTime.Metrics[0].Value, 64)
+		if err != nil || pushed >= cutoff {
+			continue
+		}
+		if err := deleteGroup(ctx, groupURL(base, job, instance)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteGroup removes the metrics group at url
+func deleteGroup(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// put replaces the metrics group at url with body
+func put(ctx context.Context, url string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
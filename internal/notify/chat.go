@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"dynamic-route-53-dns/internal/secrets"
+)
+
+// Discord embed colours for routine changes and security events
+const (
+	discordColorInfo  = 0x2563eb
+	discordColorAlert = 0xdc2626
+)
+
+// ChatMessage is an event posted to Slack or Discord. Empty fields are omitted.
+type ChatMessage struct {
+	Title      string
+	Hostname   string
+	Username   string
+	PreviousIP string
+	NewIP      string
+	SourceIP   string
+	Alert      bool // security event rather than a routine change
+}
+
+// ChatEnabled reports whether SLACK_WEBHOOK_URL or DISCORD_WEBHOOK_URL is set
+func ChatEnabled() bool {
+	return secrets.Get("SLACK_WEBHOOK_URL") != "" || secrets.Get("DISCORD_WEBHOOK_URL") != ""
+}
+
+// fields returns the message's non-empty fields as name/value pairs, in display order
+func (m *ChatMessage) fields() [][2]string {
+	var fields [][2]string
+	for _, f := range [][2]string{
+		{"Hostname", m.Hostname},
+		{"Username", m.Username},
+		{"Old IP", m.PreviousIP},
+		{"New IP", m.NewIP},
+		{"Source IP", m.SourceIP},
+	} {
+		if f[1] != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// slackPayload formats the message as Slack mrkdwn
+func (m *ChatMessage) slackPayload() ([]byte, error) {
+	var b strings.Builder
+	if m.Alert {
+		b.WriteString(":rotating_light: ")
+	}
+	fmt.Fprintf(&b, "*%s*", m.Title)
+	for _, f := range m.fields() {
+		fmt.Fprintf(&b, "\n• %s: `%s`", f[0], f[1])
+	}
+	return json.Marshal(map[string]string{"text": b.String()})
+}
+
+// discordPayload formats the message as a Discord embed
+func (m *ChatMessage) discordPayload() ([]byte, error) {
+	type field struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Inline bool   `json:"inline"`
+	}
+	type embed struct {
+		Title  string  `json:"title"`
+		Color  int     `json:"color"`
+		Fields []field `json:"fields"`
+	}
+
+	e := embed{Title: m.Title, Color: discordColorInfo}
+	if m.Alert {
+		e.Color = discordColorAlert
+	}
+	for _, f := range m.fields() {
+		e.Fields = append(e.Fields, field{Name: f[0], Value: f[1], Inline: true})
+	}
+	return json.Marshal(map[string][]embed{"embeds": {e}})
+}
+
+// pending tracks chat and webhook posts still in flight
+var pending sync.WaitGroup
+
+// SendChat posts a message to the configured Slack and Discord incoming
+// webhooks in the background, so a slow webhook never holds up the request
+// that triggered it. Delivery is best-effort: each post is bounded by the
+// webhook timeout and failures are logged, never returned.
+func SendChat(ctx context.Context, msg *ChatMessage) {
+	targets := []struct {
+		name    string
+		url     string
+		payload func() ([]byte, error)
+	}{
+		{"Slack", secrets.Get("SLACK_WEBHOOK_URL"), msg.slackPayload},
+		{"Discord", secrets.Get("DISCORD_WEBHOOK_URL"), msg.discordPayload},
+	}
+
+	// Posts outlive the request, so keep its values but not its deadline
+	ctx = context.WithoutCancel(ctx)
+	for _, target := range targets {
+		if target.url == "" {
+			continue
+		}
+		body, err := target.payload()
+		if err != nil {
+			fmt.Printf("Warning: Failed to build %s message: %v\n", target.name, err)
+			continue
+		}
+
+		pending.Add(1)
+		go func(name, url string, body []byte) {
+			defer pending.Done()
+			if err := PostWebhook(ctx, url, "", body); err != nil {
+				fmt.Printf("Warning: Failed to send %s message: %v\n", name, err)
+			}
+		}(target.name, target.url, body)
+	}
+}
+
+// Wait waits until chat and webhook posts in flight finish or ctx is done.
+// Call it on shutdown, and in Lambda before each invocation returns, since
+// the environment is frozen in between.
+func Wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"dynamic-route-53-dns/internal/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+var (
+	client *sesv2.Client
+	once   sync.Once
+
+	alertTo   string
+	alertFrom string
+)
+
+// sendTimeout bounds how long an alert may hold up the request that triggered it
+const sendTimeout = 5 * time.Second
+
+// Enabled reports whether email alerts are configured via ALERT_EMAIL and ALERT_FROM
+func Enabled() bool {
+	return os.Getenv("ALERT_EMAIL") != "" && os.Getenv("ALERT_FROM") != ""
+}
+
+// initClient lazily creates the SES client the first time an alert is sent
+func initClient(ctx context.Context) {
+	once.Do(func() {
+		alertTo = os.Getenv("ALERT_EMAIL")
+		alertFrom = os.Getenv("ALERT_FROM")
+
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			fmt.Printf("Warning: Failed to load SES config: %v\n", err)
+			return
+		}
+		tracing.InstrumentConfig(&cfg)
+		client = sesv2.NewFromConfig(cfg)
+	})
+}
+
+// SendAlert emails a security alert. Delivery is best-effort: failures are
+// logged and never returned, and nothing is sent when alerts are not configured.
+func SendAlert(ctx context.Context, subject, body string) {
+	if !Enabled() {
+		return
+	}
+
+	initClient(ctx)
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	_, err := client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(alertFrom),
+		Destination: &types.Destination{
+			ToAddresses: []string{alertTo},
+		},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String("[Dynamic DNS] " + subject)},
+				Body: &types.Body{
+					Text: &types.Content{Data: aws.String(body)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		fmt.Printf("Warning: Failed to send alert email: %v\n", err)
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dynamic-route-53-dns/internal/secrets"
+)
+
+// Webhook signing headers. Receivers verify a payload as follows:
+//
+//  1. Read X-Timestamp (Unix seconds) and reject it if it is more than
+//     WebhookMaxSkew away from the current time, which prevents replay.
+//  2. Compute HMAC-SHA256 with the shared secret over "<timestamp>.<body>",
+//     using the raw request body exactly as received.
+//  3. Hex-encode the digest and compare it to X-Signature, which has the form
+//     "sha256=<hex>", using a constant-time comparison.
+//
+// VerifyWebhookSignature implements these steps.
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Timestamp"
+)
+
+// WebhookMaxSkew is how far a signed timestamp may drift from the current time
+const WebhookMaxSkew = 5 * time.Minute
+
+// webhookTimeout bounds how long a webhook delivery may take
+const webhookTimeout = 5 * time.Second
+
+// WebhookURL returns the global event webhook URL, WEBHOOK_URL. Records may
+// set their own.
+func WebhookURL() string {
+	return secrets.Get("WEBHOOK_URL")
+}
+
+// WebhookSecret returns the global webhook signing secret, WEBHOOK_SECRET
+func WebhookSecret() string {
+	return secrets.Get("WEBHOOK_SECRET")
+}
+
+// Event is the JSON body posted to event webhooks
+type Event struct {
+	Type       string    `json:"type"` // "ip_change"
+	Hostname   string    `json:"hostname"`
+	PreviousIP string    `json:"previous_ip,omitempty"`
+	NewIP      string    `json:"new_ip,omitempty"`
+	SourceIP   string    `json:"source_ip,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// SendWebhook posts event to url in the background, signed with secret when
+// one is set. Delivery is best-effort like SendChat: failures are logged.
+func SendWebhook(ctx context.Context, url, secret string, event *Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("Warning: Failed to build webhook event: %v\n", err)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		if err := PostWebhook(ctx, url, secret, body); err != nil {
+			fmt.Printf("Warning: Failed to send webhook event: %v\n", err)
+		}
+	}()
+}
+
+// SignWebhook returns the X-Signature value for body sent at timestamp
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a signature and timestamp header pair against
+// body in constant time, rejecting timestamps outside WebhookMaxSkew
+func VerifyWebhookSignature(secret, signature, timestamp string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > WebhookMaxSkew {
+		return false
+	}
+
+	expected := SignWebhook(secret, ts, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// PostWebhook sends a JSON payload to url, signed with secret when one is set.
+// A per-record secret takes precedence; pass WebhookSecret() for the global one.
+func PostWebhook(ctx context.Context, url, secret string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, SignWebhook(secret, timestamp, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
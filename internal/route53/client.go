@@ -2,16 +2,27 @@ package route53
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"dynamic-route-53-dns/internal/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 var (
 	client *route53.Client
 	once   sync.Once
+
+	// allowedZones restricts which hosted zones are exposed; empty allows all
+	allowedZones map[string]bool
 )
 
 // Cache for zone data
@@ -23,39 +34,122 @@ type zoneCache struct {
 
 var cache = &zoneCache{}
 
-const cacheTTL = 5 * time.Minute
+// Cache for record lists, keyed by hosted zone ID
+type recordCache struct {
+	entries map[string]cachedRecords
+	mu      sync.RWMutex
+}
+
+type cachedRecords struct {
+	records   []Record
+	fetchedAt time.Time
+}
+
+var recordsCache = &recordCache{entries: make(map[string]cachedRecords)}
+
+// Default cache lifetimes. Zone lists rarely change; record lists are not
+// cached unless RECORD_CACHE_TTL is set, since other instances' changes only
+// show up once an entry expires.
+const (
+	defaultZoneListCacheTTL = 5 * time.Minute
+	defaultRecordCacheTTL   = time.Duration(0)
+)
+
+var (
+	zoneListCacheTTL = defaultZoneListCacheTTL
+	recordCacheTTL   = defaultRecordCacheTTL
+)
 
-// Init initializes the Route 53 client
+// Init initializes the Route 53 client.
+// ROUTE53_REGION overrides the region and ROUTE53_ROLE_ARN assumes a role via
+// STS, so DNS can live in a different account from the DynamoDB table.
 func Init(ctx context.Context) error {
 	var initErr error
 	once.Do(func() {
-		cfg, err := config.LoadDefaultConfig(ctx)
+		cfg, err := loadConfig(ctx)
 		if err != nil {
 			initErr = err
 			return
 		}
 		client = route53.NewFromConfig(cfg)
+		allowedZones = parseAllowedZones(os.Getenv("ALLOWED_ZONE_IDS"))
+		zoneListCacheTTL = parseCacheTTL("ZONE_LIST_CACHE_TTL", defaultZoneListCacheTTL)
+		recordCacheTTL = parseCacheTTL("RECORD_CACHE_TTL", defaultRecordCacheTTL)
 	})
 	return initErr
 }
 
+// parseCacheTTL reads a cache lifetime such as "30s" or "1h" from an
+// environment variable, falling back to the default when unset or invalid
+func parseCacheTTL(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		fmt.Printf("Warning: Ignoring invalid %s %q\n", name, value)
+		return fallback
+	}
+	return ttl
+}
+
+// parseAllowedZones parses a comma-separated list of hosted zone IDs
+func parseAllowedZones(value string) map[string]bool {
+	zones := make(map[string]bool)
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimPrefix(strings.TrimSpace(id), "/hostedzone/")
+		if id != "" {
+			zones[id] = true
+		}
+	}
+	return zones
+}
+
+// IsZoneAllowed reports whether a hosted zone may be used by this application
+func IsZoneAllowed(zoneID string) bool {
+	return len(allowedZones) == 0 || allowedZones[zoneID]
+}
+
+// loadConfig loads the AWS config for Route 53, applying any overrides from the environment
+func loadConfig(ctx context.Context) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if region := os.Getenv("ROUTE53_REGION"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if roleARN := os.Getenv("ROUTE53_ROLE_ARN"); roleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "dynamic-dns-route53"
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	tracing.InstrumentConfig(&cfg)
+	return cfg, nil
+}
+
 // GetClient returns the Route 53 client
 func GetClient() *route53.Client {
 	return client
 }
 
-// isCacheValid checks if the cache is still valid
-func isCacheValid() bool {
-	cache.mu.RLock()
-	defer cache.mu.RUnlock()
-	return cache.zones != nil && time.Since(cache.fetchedAt) < cacheTTL
+// SetClient replaces the Route 53 client set up by Init, such as with one
+// pointed at a fake endpoint in tests
+func SetClient(c *route53.Client) {
+	client = c
 }
 
 // getCachedZones returns cached zones if valid
 func getCachedZones() []Zone {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
-	if cache.zones != nil && time.Since(cache.fetchedAt) < cacheTTL {
+	if cache.zones != nil && time.Since(cache.fetchedAt) < zoneListCacheTTL {
 		return cache.zones
 	}
 	return nil
@@ -69,9 +163,46 @@ func setCachedZones(zones []Zone) {
 	cache.fetchedAt = time.Now()
 }
 
-// InvalidateCache clears the zone cache
+// InvalidateCache clears the zone and record caches
 func InvalidateCache() {
 	cache.mu.Lock()
-	defer cache.mu.Unlock()
 	cache.zones = nil
+	cache.mu.Unlock()
+
+	recordsCache.mu.Lock()
+	recordsCache.entries = make(map[string]cachedRecords)
+	recordsCache.mu.Unlock()
+}
+
+// recordCacheKey normalizes a hosted zone ID for the record cache
+func recordCacheKey(zoneID string) string {
+	return strings.TrimPrefix(zoneID, "/hostedzone/")
+}
+
+// getCachedRecords returns a zone's cached records if valid
+func getCachedRecords(zoneID string) ([]Record, bool) {
+	recordsCache.mu.RLock()
+	defer recordsCache.mu.RUnlock()
+	entry, ok := recordsCache.entries[recordCacheKey(zoneID)]
+	if !ok || time.Since(entry.fetchedAt) >= recordCacheTTL {
+		return nil, false
+	}
+	return entry.records, true
+}
+
+// setCachedRecords caches a zone's records when record caching is enabled
+func setCachedRecords(zoneID string, zoneRecords []Record) {
+	if recordCacheTTL <= 0 {
+		return
+	}
+	recordsCache.mu.Lock()
+	defer recordsCache.mu.Unlock()
+	recordsCache.entries[recordCacheKey(zoneID)] = cachedRecords{records: zoneRecords, fetchedAt: time.Now()}
+}
+
+// invalidateRecords drops a zone's cached records after a change
+func invalidateRecords(zoneID string) {
+	recordsCache.mu.Lock()
+	defer recordsCache.mu.Unlock()
+	delete(recordsCache.entries, recordCacheKey(zoneID))
 }
@@ -0,0 +1,33 @@
+package route53
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// MaxCommentLength is Route 53's limit, in characters, on a change batch comment
+const MaxCommentLength = 256
+
+// changeSourceKey is the context key for the initiator of Route 53 changes
+type changeSourceKey struct{}
+
+// WithChangeSource returns a context whose Route 53 changes are attributed to
+// source, such as "admin alice req <id>", in the change batch comment
+func WithChangeSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, changeSourceKey{}, source)
+}
+
+// changeComment builds a change batch comment from the action and the
+// context's change source, truncated to Route 53's limit
+func changeComment(ctx context.Context, action string) *string {
+	comment := action
+	if source, _ := ctx.Value(changeSourceKey{}).(string); source != "" {
+		comment += " by " + source
+	}
+	// Cut by runes so a multi-byte character in a username isn't split
+	if runes := []rune(comment); len(runes) > MaxCommentLength {
+		comment = string(runes[:MaxCommentLength])
+	}
+	return aws.String(comment)
+}
@@ -2,6 +2,7 @@ package route53
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -17,10 +18,28 @@ type Record struct {
 	Type   string
 	TTL    int64
 	Values []string
+	Alias  *AliasTarget // set for alias records
+
+	// Routing policy metadata, set when the record is one of several answers
+	SetIdentifier string
+	Region        string // latency routing
+	Weight        *int64 // weighted routing
+	Failover      string // PRIMARY or SECONDARY
+}
+
+// AliasTarget represents the target of an alias record
+type AliasTarget struct {
+	DNSName              string
+	HostedZoneID         string
+	EvaluateTargetHealth bool
 }
 
 // ListRecords returns all records for a zone
 func ListRecords(ctx context.Context, zoneID string) ([]Record, error) {
+	if cached, ok := getCachedRecords(zoneID); ok {
+		return cached, nil
+	}
+
 	var records []Record
 	var startName *string
 	var startType types.RRType
@@ -41,16 +60,27 @@ func ListRecords(ctx context.Context, zoneID string) ([]Record, error) {
 
 		for _, rrs := range result.ResourceRecordSets {
 			record := Record{
-				Name: strings.TrimSuffix(*rrs.Name, "."),
+				Name: recordName(*rrs.Name),
 				Type: string(rrs.Type),
 			}
 			if rrs.TTL != nil {
 				record.TTL = *rrs.TTL
 			}
+			if rrs.SetIdentifier != nil {
+				record.SetIdentifier = *rrs.SetIdentifier
+			}
+			record.Region = string(rrs.Region)
+			record.Weight = rrs.Weight
+			record.Failover = string(rrs.Failover)
 
 			// Handle alias records
 			if rrs.AliasTarget != nil {
 				record.Values = []string{fmt.Sprintf("ALIAS: %s", *rrs.AliasTarget.DNSName)}
+				record.Alias = &AliasTarget{
+					DNSName:              strings.TrimSuffix(*rrs.AliasTarget.DNSName, "."),
+					HostedZoneID:         *rrs.AliasTarget.HostedZoneId,
+					EvaluateTargetHealth: rrs.AliasTarget.EvaluateTargetHealth,
+				}
 			} else {
 				for _, rr := range rrs.ResourceRecords {
 					record.Values = append(record.Values, *rr.Value)
@@ -66,97 +96,220 @@ func ListRecords(ctx context.Context, zoneID string) ([]Record, error) {
 		startType = result.NextRecordType
 	}
 
+	setCachedRecords(zoneID, records)
 	return records, nil
 }
 
+// explainChangeError adds context to change batch errors caused by DNSSEC signing
+func explainChangeError(err error) error {
+	var invalid *types.InvalidChangeBatch
+	if errors.As(err, &invalid) && strings.Contains(strings.ToUpper(invalid.ErrorMessage()), "DNSSEC") {
+		return fmt.Errorf("change rejected because the zone is DNSSEC-signed: %w", err)
+	}
+	return err
+}
+
+// IsRecordNotFound reports whether a DELETE change failed because the record
+// set no longer exists, e.g. after it was removed by hand
+func IsRecordNotFound(err error) bool {
+	var invalid *types.InvalidChangeBatch
+	return errors.As(err, &invalid) && strings.Contains(invalid.ErrorMessage(), "but it was not found")
+}
+
+// RecordTypeForIP returns the record type (A or AAAA) for an IP address
+func RecordTypeForIP(ip string) types.RRType {
+	if net.ParseIP(ip).To4() == nil {
+		return types.RRTypeAaaa
+	}
+	return types.RRTypeA
+}
+
 // UpdateRecord creates or updates a DNS record
 func UpdateRecord(ctx context.Context, zoneID, hostname, ip string, ttl int64) error {
-	// Determine record type based on IP version
-	recordType := types.RRTypeA
-	if net.ParseIP(ip).To4() == nil {
-		recordType = types.RRTypeAaaa
+	return UpsertRecordValues(ctx, zoneID, hostname, RecordTypeForIP(ip), []string{ip}, ttl)
+}
+
+// DeleteRecord deletes a DNS record
+func DeleteRecord(ctx context.Context, zoneID, hostname, ip string, ttl int64) error {
+	return DeleteRecordValues(ctx, zoneID, hostname, RecordTypeForIP(ip), []string{ip}, ttl)
+}
+
+// txtStringLength is the longest character-string a TXT record value may hold
+const txtStringLength = 255
+
+// FormatValue converts a record value to the form Route 53 stores for its
+// type: CNAME and MX targets gain a trailing dot, and TXT data is quoted and
+// split into 255-character strings
+func FormatValue(recordType types.RRType, value string) string {
+	switch recordType {
+	case types.RRTypeCname, types.RRTypeMx:
+		return strings.TrimSuffix(value, ".") + "."
+	case types.RRTypeTxt:
+		var parts []string
+		for len(value) > txtStringLength {
+			parts = append(parts, quoteTXT(value[:txtStringLength]))
+			value = value[txtStringLength:]
+		}
+		return strings.Join(append(parts, quoteTXT(value)), " ")
+	default:
+		return value
 	}
+}
 
-	// Ensure hostname ends with a dot
-	fqdn := hostname
-	if !strings.HasSuffix(fqdn, ".") {
-		fqdn = fqdn + "."
+// quoteTXT quotes one TXT character-string, escaping quotes and backslashes
+func quoteTXT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// UpsertRecord creates or updates a single-value record set of any type,
+// formatting the value for the type with FormatValue
+func UpsertRecord(ctx context.Context, zoneID, name string, recordType types.RRType, value string, ttl int64) error {
+	return UpsertRecordValues(ctx, zoneID, name, recordType, []string{FormatValue(recordType, value)}, ttl)
+}
+
+// UpsertRecordValues creates or updates a record set holding one or more values
+func UpsertRecordValues(ctx context.Context, zoneID, hostname string, recordType types.RRType, values []string, ttl int64) error {
+	input := &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: changeComment(ctx, "DDNS update"),
+			Changes: []types.Change{
+				{
+					Action:            types.ChangeActionUpsert,
+					ResourceRecordSet: buildRecordSet(hostname, recordType, values, ttl),
+				},
+			},
+		},
+	}
+
+	err := changeRecordSets(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to update record: %w", explainChangeError(err))
 	}
 
+	return nil
+}
+
+// DeleteRecordValues deletes a record set. Route 53 only accepts the delete
+// when values and TTL exactly match the stored record set.
+func DeleteRecordValues(ctx context.Context, zoneID, hostname string, recordType types.RRType, values []string, ttl int64) error {
 	input := &route53.ChangeResourceRecordSetsInput{
 		HostedZoneId: aws.String(zoneID),
 		ChangeBatch: &types.ChangeBatch{
-			Comment: aws.String("DDNS update"),
+			Comment: changeComment(ctx, "DDNS record deletion"),
 			Changes: []types.Change{
 				{
-					Action: types.ChangeActionUpsert,
-					ResourceRecordSet: &types.ResourceRecordSet{
-						Name: aws.String(fqdn),
-						Type: recordType,
-						TTL:  aws.Int64(ttl),
-						ResourceRecords: []types.ResourceRecord{
-							{
-								Value: aws.String(ip),
-							},
-						},
-					},
+					Action:            types.ChangeActionDelete,
+					ResourceRecordSet: buildRecordSet(hostname, recordType, values, ttl),
 				},
 			},
 		},
 	}
 
-	_, err := client.ChangeResourceRecordSets(ctx, input)
+	err := changeRecordSets(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to update record: %w", err)
+		return fmt.Errorf("failed to delete record: %w", explainChangeError(err))
 	}
 
 	return nil
 }
 
-// DeleteRecord deletes a DNS record
-func DeleteRecord(ctx context.Context, zoneID, hostname, ip string, ttl int64) error {
-	// Determine record type based on IP version
-	recordType := types.RRTypeA
-	if net.ParseIP(ip).To4() == nil {
-		recordType = types.RRTypeAaaa
+// UpsertAliasRecord creates or updates an alias record (A or AAAA), e.g. for
+// pointing a zone apex at CloudFront or a load balancer
+func UpsertAliasRecord(ctx context.Context, zoneID, name string, recordType types.RRType, targetDNSName, targetZoneID string, evaluateTargetHealth bool) error {
+	input := &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: changeComment(ctx, "DDNS alias update"),
+			Changes: []types.Change{
+				{
+					Action:            types.ChangeActionUpsert,
+					ResourceRecordSet: buildAliasRecordSet(name, recordType, targetDNSName, targetZoneID, evaluateTargetHealth),
+				},
+			},
+		},
 	}
 
-	// Ensure hostname ends with a dot
-	fqdn := hostname
-	if !strings.HasSuffix(fqdn, ".") {
-		fqdn = fqdn + "."
+	err := changeRecordSets(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to update alias record: %w", explainChangeError(err))
 	}
 
+	return nil
+}
+
+// DeleteAliasRecord deletes an alias record. The alias target must match the
+// stored record exactly for Route 53 to accept the delete.
+func DeleteAliasRecord(ctx context.Context, zoneID, name string, recordType types.RRType, targetDNSName, targetZoneID string, evaluateTargetHealth bool) error {
 	input := &route53.ChangeResourceRecordSetsInput{
 		HostedZoneId: aws.String(zoneID),
 		ChangeBatch: &types.ChangeBatch{
-			Comment: aws.String("DDNS record deletion"),
+			Comment: changeComment(ctx, "DDNS alias deletion"),
 			Changes: []types.Change{
 				{
-					Action: types.ChangeActionDelete,
-					ResourceRecordSet: &types.ResourceRecordSet{
-						Name: aws.String(fqdn),
-						Type: recordType,
-						TTL:  aws.Int64(ttl),
-						ResourceRecords: []types.ResourceRecord{
-							{
-								Value: aws.String(ip),
-							},
-						},
-					},
+					Action:            types.ChangeActionDelete,
+					ResourceRecordSet: buildAliasRecordSet(name, recordType, targetDNSName, targetZoneID, evaluateTargetHealth),
 				},
 			},
 		},
 	}
 
-	_, err := client.ChangeResourceRecordSets(ctx, input)
+	err := changeRecordSets(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to delete record: %w", err)
+		return fmt.Errorf("failed to delete alias record: %w", explainChangeError(err))
 	}
 
 	return nil
 }
 
+// buildAliasRecordSet builds an alias resource record set
+func buildAliasRecordSet(name string, recordType types.RRType, targetDNSName, targetZoneID string, evaluateTargetHealth bool) *types.ResourceRecordSet {
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn = fqdn + "."
+	}
+
+	return &types.ResourceRecordSet{
+		Name: aws.String(fqdn),
+		Type: recordType,
+		AliasTarget: &types.AliasTarget{
+			DNSName:              aws.String(targetDNSName),
+			HostedZoneId:         aws.String(targetZoneID),
+			EvaluateTargetHealth: evaluateTargetHealth,
+		},
+	}
+}
+
+// buildRecordSet builds a resource record set for the given values
+func buildRecordSet(hostname string, recordType types.RRType, values []string, ttl int64) *types.ResourceRecordSet {
+	// Ensure hostname ends with a dot
+	fqdn := hostname
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn = fqdn + "."
+	}
+
+	records := make([]types.ResourceRecord, 0, len(values))
+	for _, value := range values {
+		records = append(records, types.ResourceRecord{
+			Value: aws.String(value),
+		})
+	}
+
+	return &types.ResourceRecordSet{
+		Name:            aws.String(fqdn),
+		Type:            recordType,
+		TTL:             aws.Int64(ttl),
+		ResourceRecords: records,
+	}
+}
+
+// recordName turns a record set name from Route 53 into a hostname: the
+// trailing dot is dropped and the escaped wildcard \052 becomes "*"
+func recordName(name string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(name, "."), `\052`, "*")
+}
+
 // GetRecord retrieves a specific DNS record
 func GetRecord(ctx context.Context, zoneID, hostname string, recordType types.RRType) (*Record, error) {
 	fqdn := hostname
@@ -177,8 +330,8 @@ func GetRecord(ctx context.Context, zoneID, hostname string, recordType types.RR
 	}
 
 	for _, rrs := range result.ResourceRecordSets {
-		name := strings.TrimSuffix(*rrs.Name, ".")
-		if name == hostname && rrs.Type == recordType {
+		name := recordName(*rrs.Name)
+		if strings.EqualFold(name, strings.TrimSuffix(hostname, ".")) && rrs.Type == recordType {
 			record := &Record{
 				Name: name,
 				Type: string(rrs.Type),
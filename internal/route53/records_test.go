@@ -0,0 +1,85 @@
+package route53
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// fakeRoute53 answers every request with a fixed XML body
+type fakeRoute53 struct {
+	body string
+}
+
+func (f fakeRoute53) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(f.body)),
+		Request:    req,
+	}, nil
+}
+
+// useFakeClient points the package client at a fake returning body
+func useFakeClient(t *testing.T, body string) {
+	t.Helper()
+	previous := client
+	client = route53.New(route53.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  fakeRoute53{body: body},
+	})
+	t.Cleanup(func() { client = previous })
+}
+
+const wildcardListResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<ListResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <ResourceRecordSets>
+    <ResourceRecordSet>
+      <Name>\052.home.example.com.</Name>
+      <Type>A</Type>
+      <TTL>60</TTL>
+      <ResourceRecords>
+        <ResourceRecord><Value>203.0.113.7</Value></ResourceRecord>
+      </ResourceRecords>
+    </ResourceRecordSet>
+  </ResourceRecordSets>
+  <IsTruncated>false</IsTruncated>
+  <MaxItems>1</MaxItems>
+</ListResourceRecordSetsResponse>`
+
+func TestGetRecordMatchesEscapedWildcard(t *testing.T) {
+	useFakeClient(t, wildcardListResponse)
+
+	record, err := GetRecord(context.Background(), "Z1", "*.Home.example.com", types.RRTypeA)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record == nil {
+		t.Fatal("GetRecord found no record for the escaped wildcard")
+	}
+	if record.Name != "*.home.example.com" {
+		t.Errorf("Name = %q, want *.home.example.com", record.Name)
+	}
+	if len(record.Values) != 1 || record.Values[0] != "203.0.113.7" {
+		t.Errorf("Values = %v, want [203.0.113.7]", record.Values)
+	}
+}
+
+func TestGetRecordIgnoresOtherNames(t *testing.T) {
+	useFakeClient(t, wildcardListResponse)
+
+	record, err := GetRecord(context.Background(), "Z1", "home.example.com", types.RRTypeA)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record != nil {
+		t.Errorf("GetRecord returned %+v for a different name", record)
+	}
+}
@@ -0,0 +1,89 @@
+package route53
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrThrottled is returned when Route 53 kept throttling a change until the
+// retry budget ran out; callers should ask clients to retry later
+var ErrThrottled = errors.New("route 53 is throttling changes")
+
+// Retry budget for ChangeResourceRecordSets, which Route 53 limits to five
+// requests per second per account
+const (
+	changeMaxAttempts  = 5
+	changeBaseDelay    = 200 * time.Millisecond
+	changeMaxDelay     = 2 * time.Second
+	changeRetryTimeout = 10 * time.Second
+)
+
+// isThrottleError reports whether err is a throttling or
+// PriorRequestNotComplete error worth retrying
+func isThrottleError(err error) bool {
+	var prior *types.PriorRequestNotComplete
+	if errors.As(err, &prior) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "Throttling", "ThrottlingException", "PriorRequestNotComplete":
+			return true
+		}
+	}
+	return false
+}
+
+// changeRecordSets submits a change batch, retrying throttled requests with
+// exponential backoff and jitter until the attempts or deadline run out.
+// This loop is the only retry: the SDK's own retryer is turned off for the
+// call so its attempts don't multiply ours.
+func changeRecordSets(ctx context.Context, input *route53.ChangeResourceRecordSetsInput) error {
+	// Even a failed batch may have been applied, so drop the zone's records
+	defer invalidateRecords(aws.ToString(input.HostedZoneId))
+
+	ctx, cancel := context.WithTimeout(ctx, changeRetryTimeout)
+	defer cancel()
+
+	delay := changeBaseDelay
+	for attempt := 1; ; attempt++ {
+		_, err := client.ChangeResourceRecordSets(ctx, input, withoutSDKRetries)
+		if err == nil || !isThrottleError(err) {
+			return err
+		}
+		if attempt == changeMaxAttempts {
+			return fmt.Errorf("%w after %d attempts: %v", ErrThrottled, attempt, err)
+		}
+
+		// Jitter (half the delay plus a random part of the delay) keeps
+		// concurrent updates from retrying in lockstep
+		wait := time.Duration(rand.Int63n(int64(delay))) + delay/2
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrThrottled, err)
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if delay > changeMaxDelay {
+			delay = changeMaxDelay
+		}
+	}
+}
+
+// withoutSDKRetries limits a call to a single attempt, for callers that
+// retry themselves
+func withoutSDKRetries(o *route53.Options) {
+	o.Retryer = retry.AddWithMaxAttempts(o.Retryer, 1)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 )
 
@@ -15,6 +17,7 @@ type Zone struct {
 	RecordCount int64
 	IsPrivate   bool
 	Comment     string
+	DNSSEC      bool // DNSSEC signing is active (or being changed) for the zone
 }
 
 // ListZones returns all hosted zones
@@ -38,6 +41,9 @@ func ListZones(ctx context.Context) ([]Zone, error) {
 		}
 
 		for _, hz := range result.HostedZones {
+			if !IsZoneAllowed(strings.TrimPrefix(*hz.Id, "/hostedzone/")) {
+				continue
+			}
 			zone := Zone{
 				ID:          strings.TrimPrefix(*hz.Id, "/hostedzone/"),
 				Name:        strings.TrimSuffix(*hz.Name, "."),
@@ -56,6 +62,8 @@ func ListZones(ctx context.Context) ([]Zone, error) {
 		marker = result.NextMarker
 	}
 
+	setDNSSECStatus(ctx, zones)
+
 	// Update cache
 	setCachedZones(zones)
 
@@ -64,6 +72,10 @@ func ListZones(ctx context.Context) ([]Zone, error) {
 
 // GetZone returns a specific hosted zone by ID
 func GetZone(ctx context.Context, zoneID string) (*Zone, error) {
+	if !IsZoneAllowed(zoneID) {
+		return nil, fmt.Errorf("hosted zone %s is not allowed", zoneID)
+	}
+
 	// Check cache first
 	if cached := getCachedZones(); cached != nil {
 		for _, z := range cached {
@@ -89,6 +101,48 @@ func GetZone(ctx context.Context, zoneID string) (*Zone, error) {
 	if result.HostedZone.Config != nil && result.HostedZone.Config.Comment != nil {
 		zone.Comment = *result.HostedZone.Config.Comment
 	}
+	zone.DNSSEC = isDNSSECSigned(ctx, zone)
 
 	return zone, nil
 }
+
+// dnssecConcurrency bounds the GetDNSSEC calls made at once when listing zones
+const dnssecConcurrency = 5
+
+// setDNSSECStatus looks up the DNSSEC status of each zone concurrently
+func setDNSSECStatus(ctx context.Context, zones []Zone) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < dnssecConcurrency && w < len(zones); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				zones[i].DNSSEC = isDNSSECSigned(ctx, &zones[i])
+			}
+		}()
+	}
+
+	for i := range zones {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// isDNSSECSigned reports whether a zone has DNSSEC signing enabled.
+// Private zones cannot be signed, and lookup failures are treated as unsigned.
+func isDNSSECSigned(ctx context.Context, zone *Zone) bool {
+	if zone.IsPrivate {
+		return false
+	}
+
+	result, err := client.GetDNSSEC(ctx, &route53.GetDNSSECInput{
+		HostedZoneId: aws.String(zone.ID),
+	})
+	if err != nil || result.Status == nil || result.Status.ServeSignature == nil {
+		return false
+	}
+
+	return *result.Status.ServeSignature != "NOT_SIGNING"
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"dynamic-route-53-dns/internal/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+var (
+	values map[string]string
+	mu     sync.RWMutex
+)
+
+// SecretARN returns ADMIN_SECRET_ARN, the Secrets Manager secret holding
+// credentials, or an empty string to read them from env vars
+func SecretARN() string {
+	return os.Getenv("ADMIN_SECRET_ARN")
+}
+
+// Init fetches the secret named by ADMIN_SECRET_ARN once and caches it. The
+// secret is a JSON object keyed by env var name, e.g.
+// {"ADMIN_USERNAME": "...", "ADMIN_PASSWORD": "...", "JWT_SECRET": "..."}.
+// Without an ARN nothing is fetched and Get reads env vars.
+func Init(ctx context.Context) error {
+	arn := SecretARN()
+	if arn == "" {
+		return nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	tracing.InstrumentConfig(&cfg)
+
+	result, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(arn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read secret: %w", err)
+	}
+	if result.SecretString == nil {
+		return fmt.Errorf("secret %s has no string value", arn)
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(*result.SecretString), &parsed); err != nil {
+		return fmt.Errorf("secret %s is not a JSON object of strings: %w", arn, err)
+	}
+
+	mu.Lock()
+	values = parsed
+	mu.Unlock()
+	return nil
+}
+
+// Get returns the named credential from the cached secret, falling back to
+// the env var of the same name when the secret doesn't define it
+func Get(name string) string {
+	mu.RLock()
+	value, ok := values[name]
+	mu.RUnlock()
+	if ok {
+		return value
+	}
+	return os.Getenv(name)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/notify"
+)
+
+// BadAuthAlertThreshold is how many badauth updates a record may collect in
+// an hour before an alert is sent
+const BadAuthAlertThreshold = 10
+
+// lockoutAlertKey is the rate limit key throttling lockout alerts per client IP
+func lockoutAlertKey(clientIP string) string {
+	return fmt.Sprintf("alert:lockout:%s", clientIP)
+}
+
+// badAuthAlertKey is the rate limit key counting a hostname's badauth updates
+func badAuthAlertKey(hostname string) string {
+	return fmt.Sprintf("alert:badauth:%s", hostname)
+}
+
+// alertLockout emails a notice that a login lockout was triggered. At most one
+// alert is sent per client IP per lockout window.
+func alertLockout(ctx context.Context, store database.Store, policy database.LockoutPolicy, username, clientIP string, lockedUntil time.Time) {
+	if !notify.Enabled() && !notify.ChatEnabled() {
+		return
+	}
+
+	key := lockoutAlertKey(clientIP)
+	window := int64(policy.LockoutDuration.Seconds())
+	if _, exceeded, err := store.IncrementRateLimit(ctx, key, 1, window); err != nil || exceeded {
+		return
+	}
+
+	notify.SendAlert(ctx, "Login locked out after failed attempts", fmt.Sprintf(
+		"%d failed login attempts for username %q from %s.\nLogin is locked until %s.\n",
+		policy.MaxAttempts, username, clientIP, lockedUntil.Format(time.RFC3339),
+	))
+	notify.SendChat(ctx, &notify.ChatMessage{
+		Title:    "Login locked until " + lockedUntil.Format(time.RFC3339),
+		Username: username,
+		SourceIP: clientIP,
+		Alert:    true,
+	})
+}
+
+// alertBadAuth counts a badauth update for hostname and emails a notice when
+// the hourly count reaches BadAuthAlertThreshold
+func alertBadAuth(ctx context.Context, store database.Store, hostname, sourceIP string) {
+	if !notify.Enabled() {
+		return
+	}
+
+	key := badAuthAlertKey(hostname)
+	count, _, err := store.IncrementRateLimit(ctx, key, BadAuthAlertThreshold, 3600)
+	if err != nil || count != BadAuthAlertThreshold {
+		return
+	}
+
+	notify.SendAlert(ctx, "Repeated failed DDNS updates for "+hostname, fmt.Sprintf(
+		"%d updates with an invalid token for %s in the last hour.\nMost recent attempt came from %s.\n",
+		count, hostname, sourceIP,
+	))
+}
+
+// notifyIPChange posts a published IP change to Slack and Discord and to
+// the record's event webhook
+func notifyIPChange(ctx context.Context, record *database.DDNSRecord, previousIP, ip, sourceIP string) {
+	hostname := record.Hostname
+	if url, secret := recordWebhook(record); url != "" {
+		notify.SendWebhook(ctx, url, secret, &notify.Event{
+			Type:       "ip_change",
+			Hostname:   hostname,
+			PreviousIP: previousIP,
+			NewIP:      ip,
+			SourceIP:   sourceIP,
+			Timestamp:  time.Now().UTC(),
+		})
+	}
+
+	if !notify.ChatEnabled() {
+		return
+	}
+
+	notify.SendChat(ctx, &notify.ChatMessage{
+		Title:      "IP changed for " + hostname,
+		Hostname:   hostname,
+		PreviousIP: previousIP,
+		NewIP:      ip,
+		SourceIP:   sourceIP,
+	})
+}
+
+// recordWebhook returns the event webhook URL and signing secret for a
+// record: its own when set, otherwise WEBHOOK_URL and WEBHOOK_SECRET
+func recordWebhook(record *database.DDNSRecord) (string, string) {
+	if record.WebhookURL == "" {
+		return notify.WebhookURL(), notify.WebhookSecret()
+	}
+	if record.WebhookSecret != "" {
+		return record.WebhookURL, record.WebhookSecret
+	}
+	return record.WebhookURL, notify.WebhookSecret()
+}
+
+// notifyUpdateLocked posts a notice that a hostname's updates were locked,
+// after too many failed token checks or the address flapping between clients
+func notifyUpdateLocked(ctx context.Context, hostname, reason, sourceIP string) {
+	if !notify.ChatEnabled() {
+		return
+	}
+
+	notify.SendChat(ctx, &notify.ChatMessage{
+		Title:    fmt.Sprintf("Updates locked for %s: %s", hostname, reason),
+		Hostname: hostname,
+		SourceIP: sourceIP,
+		Alert:    true,
+	})
+}
@@ -4,30 +4,77 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"dynamic-route-53-dns/internal/auth"
 	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/secrets"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthService handles authentication logic
 type AuthService struct {
-	sessionManager *auth.SessionManager
+	store          database.Store
+	sessionManager auth.SessionManager
 	adminUsername  string
 	adminPassword  string
+	lockoutPolicy  database.LockoutPolicy
+	challenge      auth.ChallengeVerifier // nil disables step-up challenges
 }
 
 // NewAuthService creates a new auth service
 func NewAuthService() *AuthService {
 	return &AuthService{
+		store:          database.GetStore(),
 		sessionManager: auth.NewSessionManager(),
-		adminUsername:  os.Getenv("ADMIN_USERNAME"),
-		adminPassword:  os.Getenv("ADMIN_PASSWORD"),
+		adminUsername:  secrets.Get("ADMIN_USERNAME"),
+		adminPassword:  secrets.Get("ADMIN_PASSWORD"),
+		lockoutPolicy:  lockoutPolicyFromEnv(),
+		challenge:      auth.NewChallengeVerifierFromEnv(),
 	}
 }
 
+// lockoutPolicyFromEnv reads LOGIN_MAX_ATTEMPTS, LOGIN_CHALLENGE_AFTER,
+// LOGIN_LOCKOUT_DURATION and LOGIN_ATTEMPT_WINDOW, falling back to the default for unset or invalid values
+func lockoutPolicyFromEnv() database.LockoutPolicy {
+	policy := database.DefaultLockoutPolicy
+
+	if value := os.Getenv("LOGIN_MAX_ATTEMPTS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			policy.MaxAttempts = n
+		} else {
+			fmt.Printf("Warning: Ignoring invalid LOGIN_MAX_ATTEMPTS %q\n", value)
+		}
+	}
+	if value := os.Getenv("LOGIN_CHALLENGE_AFTER"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			policy.ChallengeAfter = n
+		} else {
+			fmt.Printf("Warning: Ignoring invalid LOGIN_CHALLENGE_AFTER %q\n", value)
+		}
+	}
+	policy.LockoutDuration = durationFromEnv("LOGIN_LOCKOUT_DURATION", policy.LockoutDuration)
+	policy.AttemptWindow = durationFromEnv("LOGIN_ATTEMPT_WINDOW", policy.AttemptWindow)
+
+	return policy
+}
+
+// durationFromEnv parses a positive duration such as "15m" from an environment variable
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		fmt.Printf("Warning: Ignoring invalid %s %q\n", name, value)
+		return fallback
+	}
+	return d
+}
+
 // LoginResult represents the result of a login attempt
 type LoginResult struct {
 	Success     bool
@@ -35,47 +82,120 @@ type LoginResult struct {
 	Error       string
 	IsLocked    bool
 	LockedUntil time.Time
+
+	// AttemptsRemaining is the number of failures left before lockout,
+	// or zero when unknown or locked
+	AttemptsRemaining int
+
+	// Challenge is set when the next login must solve a step-up challenge
+	Challenge *auth.ChallengeWidget
 }
 
-// Login attempts to authenticate a user
-func (s *AuthService) Login(ctx context.Context, username, password string) *LoginResult {
-	// Check if account is locked
-	locked, lockedUntil, err := database.IsAccountLocked(ctx, username)
+// LoginRequest represents a login form submission
+type LoginRequest struct {
+	Username          string
+	Password          string
+	ClientIP          string // identifies the caller for lockouts, alerts and challenge checks
+	UserAgent         string
+	ChallengeResponse string // solved challenge token, if one was presented
+}
+
+// lockedResult builds the result for a locked-out login
+func lockedResult(lockedUntil time.Time) *LoginResult {
+	wait := time.Until(lockedUntil).Round(time.Minute)
+	if wait < time.Minute {
+		wait = time.Minute
+	}
+	return &LoginResult{
+		Success:     false,
+		IsLocked:    true,
+		LockedUntil: lockedUntil,
+		Error:       fmt.Sprintf("Too many failed attempts. Try again in %d minute(s).", int(wait.Minutes())),
+	}
+}
+
+// LoginAttemptKey identifies the failed-login counter for a username tried
+// from a client IP. Lockouts are per client so that someone guessing
+// passwords cannot lock the admin out from everywhere else.
+func LoginAttemptKey(username, clientIP string) string {
+	if clientIP == "" {
+		return username
+	}
+	return username + "@" + clientIP
+}
+
+// Login attempts to authenticate a user. Failures are counted whether or not
+// the username exists, so the remaining-attempts feedback does not reveal
+// valid usernames. Past the policy's soft threshold a challenge must be solved
+// before credentials are checked; the hard threshold locks the username for
+// the client IP the failures came from.
+func (s *AuthService) Login(ctx context.Context, req *LoginRequest) *LoginResult {
+	username := req.Username
+	attemptKey := LoginAttemptKey(username, req.ClientIP)
+
+	// Check if this client is locked out
+	attempt, err := s.store.GetLoginAttempt(ctx, attemptKey)
 	if err != nil {
 		return &LoginResult{
 			Success: false,
 			Error:   "Internal error",
 		}
 	}
-	if locked {
-		return &LoginResult{
-			Success:     false,
-			IsLocked:    true,
-			LockedUntil: lockedUntil,
-			Error:       fmt.Sprintf("Account locked until %s", lockedUntil.Format(time.RFC3339)),
+	if attempt.IsLocked() {
+		return lockedResult(attempt.LockedUntil)
+	}
+
+	// Require a solved challenge once past the soft threshold
+	if s.challenge != nil && attempt.NeedsChallenge(s.lockoutPolicy) {
+		ok, err := s.challenge.Verify(ctx, req.ChallengeResponse, req.ClientIP)
+		if err != nil {
+			fmt.Printf("Warning: Failed to verify login challenge: %v\n", err)
+		}
+		if !ok {
+			return &LoginResult{
+				Success:           false,
+				Error:             "Please complete the verification challenge",
+				AttemptsRemaining: attempt.AttemptsRemaining(s.lockoutPolicy),
+				Challenge:         s.challenge.Widget(),
+			}
 		}
 	}
 
 	// Validate credentials
-	if username != s.adminUsername || password != s.adminPassword {
+	if username != s.adminUsername || req.Password != s.adminPassword {
 		// Record failed attempt
-		locked, lockedUntil, _ = database.RecordLoginAttempt(ctx, username, false)
-		if locked {
+		attempt, err := s.store.RecordLoginAttempt(ctx, attemptKey, false, s.lockoutPolicy)
+		if err != nil {
+			fmt.Printf("Warning: Failed to record login attempt: %v\n", err)
 			return &LoginResult{
-				Success:     false,
-				IsLocked:    true,
-				LockedUntil: lockedUntil,
-				Error:       fmt.Sprintf("Account locked until %s", lockedUntil.Format(time.RFC3339)),
+				Success: false,
+				Error:   "Invalid username or password",
 			}
 		}
-		return &LoginResult{
-			Success: false,
-			Error:   "Invalid username or password",
+		if attempt.IsLocked() {
+			alertLockout(ctx, s.store, s.lockoutPolicy, username, req.ClientIP, attempt.LockedUntil)
+			return lockedResult(attempt.LockedUntil)
+		}
+		result := &LoginResult{
+			Success:           false,
+			Error:             "Invalid username or password",
+			AttemptsRemaining: attempt.AttemptsRemaining(s.lockoutPolicy),
 		}
+		if s.challenge != nil && attempt.NeedsChallenge(s.lockoutPolicy) {
+			result.Challenge = s.challenge.Widget()
+		}
+		return result
 	}
 
 	// Record successful login
-	_, _, _ = database.RecordLoginAttempt(ctx, username, true)
+	_, _ = s.store.RecordLoginAttempt(ctx, attemptKey, true, s.lockoutPolicy)
+	if err := s.store.CreateLoginRecord(ctx, &database.LoginRecord{
+		Username:  username,
+		SourceIP:  req.ClientIP,
+		UserAgent: req.UserAgent,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to record login history: %v\n", err)
+	}
 
 	// Create session
 	sessionID, err := s.sessionManager.CreateSession(ctx, username)
@@ -97,14 +217,64 @@ func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
 	return s.sessionManager.DeleteSession(ctx, sessionID)
 }
 
+// LoginHistory returns a user's most recent successful logins, newest first
+func (s *AuthService) LoginHistory(ctx context.Context, username string, limit int32) ([]database.LoginRecord, error) {
+	return s.store.GetLoginHistory(ctx, username, limit)
+}
+
+// LastLogin returns the login before the current one, or nil if there is none
+func (s *AuthService) LastLogin(ctx context.Context, username string) *database.LoginRecord {
+	logins, err := s.store.GetLoginHistory(ctx, username, 2)
+	if err != nil || len(logins) < 2 {
+		return nil
+	}
+	return &logins[1]
+}
+
+// ChallengeField returns the login form field carrying the challenge
+// response, or an empty string when challenges are disabled
+func (s *AuthService) ChallengeField() string {
+	if s.challenge == nil {
+		return ""
+	}
+	return s.challenge.Widget().ResponseField
+}
+
 // ValidateSession validates a session and returns the username
 func (s *AuthService) ValidateSession(ctx context.Context, sessionID string) (string, bool) {
 	return s.sessionManager.ValidateSession(ctx, sessionID)
 }
 
+// RefreshSession returns a replacement session ID when the session is due to
+// be reissued, or false when it has been revoked
+func (s *AuthService) RefreshSession(ctx context.Context, sessionID string) (string, bool) {
+	return s.sessionManager.RefreshSession(ctx, sessionID)
+}
+
+// DefaultBcryptCost is the bcrypt cost used when BCRYPT_COST is not set
+const DefaultBcryptCost = 10
+
+// BcryptCost returns the configured bcrypt cost for new token hashes
+func BcryptCost() int {
+	cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return DefaultBcryptCost
+	}
+	return cost
+}
+
+// NeedsRehash reports whether a stored hash was created below the configured cost
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost < BcryptCost()
+}
+
 // HashToken hashes a token using bcrypt
 func HashToken(token string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(token), 10)
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), BcryptCost())
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dynamic-route-53-dns/internal/auth"
+	"dynamic-route-53-dns/internal/database"
+)
+
+// newTestAuthService returns an AuthService for admin/correct-password
+// backed by an in-memory store
+func newTestAuthService(t *testing.T, policy database.LockoutPolicy) (*AuthService, *database.MemoryStore) {
+	t.Helper()
+
+	store := database.NewMemoryStore()
+	previous := database.GetStore()
+	database.SetStore(store)
+	t.Cleanup(func() { database.SetStore(previous) })
+
+	return &AuthService{
+		store:          store,
+		sessionManager: auth.NewSessionManager(),
+		adminUsername:  "admin",
+		adminPassword:  "correct-password",
+		lockoutPolicy:  policy,
+	}, store
+}
+
+func TestLoginLocksOutAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestAuthService(t, database.LockoutPolicy{
+		MaxAttempts:     3,
+		LockoutDuration: 15 * time.Minute,
+		AttemptWindow:   time.Hour,
+	})
+	login := func(password string) *LoginResult {
+		return s.Login(ctx, &LoginRequest{Username: "admin", Password: password, ClientIP: "192.0.2.1"})
+	}
+
+	for want := 2; want > 0; want-- {
+		result := login("wrong")
+		if result.Success || result.IsLocked {
+			t.Fatalf("failed login = %+v; want an unlocked failure", result)
+		}
+		if result.AttemptsRemaining != want {
+			t.Errorf("AttemptsRemaining = %d; want %d", result.AttemptsRemaining, want)
+		}
+	}
+
+	result := login("wrong")
+	if !result.IsLocked {
+		t.Fatalf("last allowed failure = %+v; want the account locked", result)
+	}
+	if until := time.Until(result.LockedUntil); until < 14*time.Minute || until > 15*time.Minute {
+		t.Errorf("LockedUntil is %v away; want the 15 minute lockout", until)
+	}
+
+	// The right password is refused while the lock holds
+	result = login("correct-password")
+	if result.Success || !result.IsLocked {
+		t.Fatalf("login while locked = %+v; want it refused as locked", result)
+	}
+
+	// The lock is per client: the admin can still log in from elsewhere
+	other := s.Login(ctx, &LoginRequest{Username: "admin", Password: "correct-password", ClientIP: "198.51.100.9"})
+	if !other.Success {
+		t.Fatalf("login from another client = %+v; want success", other)
+	}
+
+	// Clearing the lock (as the admin limits page does) allows login again
+	if err := store.ClearLoginAttempts(ctx, LoginAttemptKey("admin", "192.0.2.1")); err != nil {
+		t.Fatalf("ClearLoginAttempts: %v", err)
+	}
+	result = login("correct-password")
+	if !result.Success || result.SessionID == "" {
+		t.Fatalf("login after clearing = %+v; want a session", result)
+	}
+	if _, valid := s.ValidateSession(ctx, result.SessionID); !valid {
+		t.Error("session from a successful login is not valid")
+	}
+}
+
+func TestSuccessfulLoginResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestAuthService(t, database.LockoutPolicy{
+		MaxAttempts:     3,
+		LockoutDuration: 15 * time.Minute,
+		AttemptWindow:   time.Hour,
+	})
+	login := func(password string) *LoginResult {
+		return s.Login(ctx, &LoginRequest{Username: "admin", Password: password})
+	}
+
+	login("wrong")
+	login("wrong")
+	if result := login("correct-password"); !result.Success {
+		t.Fatalf("login = %+v; want success before the limit", result)
+	}
+
+	result := login("wrong")
+	if result.IsLocked || result.AttemptsRemaining != 2 {
+		t.Errorf("failure after a success = %+v; want the count restarted", result)
+	}
+}
@@ -3,30 +3,100 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
+	"net/url"
+	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"dynamic-route-53-dns/internal/auth"
 	"dynamic-route-53-dns/internal/database"
 	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
 )
 
 // DDNSService handles DDNS record management
-type DDNSService struct{}
+type DDNSService struct {
+	store database.Store
+}
 
 // NewDDNSService creates a new DDNS service
 func NewDDNSService() *DDNSService {
-	return &DDNSService{}
+	return &DDNSService{store: database.GetStore()}
+}
+
+// TTL bounds accepted for DDNS records, in seconds
+const (
+	MinTTL = 60
+	MaxTTL = 86400
+)
+
+// fallbackTTL is the default TTL when DDNS_DEFAULT_TTL is unset or invalid
+const fallbackTTL = 60
+
+// DefaultTTL returns DDNS_DEFAULT_TTL, the TTL for new records that don't
+// specify one, falling back to 60 seconds when unset or out of bounds
+func DefaultTTL() int64 {
+	value := os.Getenv("DDNS_DEFAULT_TTL")
+	if value == "" {
+		return fallbackTTL
+	}
+	ttl, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ttl < MinTTL || ttl > MaxTTL {
+		fmt.Printf("Warning: Ignoring DDNS_DEFAULT_TTL %q, must be between %d and %d\n", value, MinTTL, MaxTTL)
+		return fallbackTTL
+	}
+	return ttl
+}
+
+// EffectiveTTL returns the TTL published for a record. A stored TTL of 0
+// means "use the default" rather than a literal 0-second TTL; any positive
+// TTL, even one below MinTTL, is published as is.
+func EffectiveTTL(record *database.DDNSRecord) int64 {
+	if record.TTL <= 0 {
+		return DefaultTTL()
+	}
+	return record.TTL
 }
 
 // DDNSConfig represents configuration for creating a DDNS record
 type DDNSConfig struct {
-	Hostname  string
-	ZoneID    string
-	ZoneName  string
-	TTL       int64
-	InitialIP string
+	Hostname    string
+	ZoneID      string
+	ZoneName    string
+	TTL         int64
+	InitialIP   string
+	Description string
+	Tags        map[string]string
+
+	// AllowPrivate confirms a record in a private hosted zone, which only
+	// resolves inside its VPCs
+	AllowPrivate bool
+
+	// Wildcard also publishes the address at *.<hostname>
+	Wildcard bool
+
+	// Token is an update token chosen by the user; empty generates one
+	Token string
+
+	// IdempotencyKey makes a retried create recognise the original request;
+	// keys are scoped to Username
+	IdempotencyKey string
+	Username       string
+
+	// Adopted marks InitialIP as already live in Route 53, so the record is
+	// stored without republishing it
+	Adopted bool
+
+	// Overwrite confirms replacing a different record Route 53 already holds
+	// for the hostname (or its wildcard), whether InitialIP publishes over it
+	// now or the first client update does later
+	Overwrite bool
 }
 
 // CreateDDNSResult represents the result of creating a DDNS record
@@ -35,6 +105,73 @@ type CreateDDNSResult struct {
 	Token    string
 	Hostname string
 	Error    string
+	Replayed bool   // an earlier request with the same idempotency key created the record; Token is empty
+	RevealID string // the token reveal to show, shared by replays of the request
+
+	// Err is the typed cause of a failure when callers may act on it:
+	// ErrRecordExists when Route 53 already holds a different record, in
+	// which case a retry with Overwrite replaces it
+	Err error
+}
+
+// MaxDescriptionLength caps the operator-facing description of a record
+const MaxDescriptionLength = 200
+
+// normalizeDescription trims a description and checks its length
+func normalizeDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if len([]rune(description)) > MaxDescriptionLength {
+		return "", validationErrorf("description must be at most %d characters", MaxDescriptionLength)
+	}
+	return description, nil
+}
+
+// MaxTags caps the number of tags on a record
+const MaxTags = 20
+
+// MaxTagValueLength caps the length of a single tag value
+const MaxTagValueLength = 128
+
+// tagKeyRegex validates tag keys
+var tagKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// ParseTags parses "key=value" pairs separated by commas or newlines
+func ParseTags(value string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || !tagKeyRegex.MatchString(key) {
+			return nil, validationErrorf("invalid tag %q: use key=value with letters, digits, '.', '_' or '-' in the key", pair)
+		}
+		if len([]rune(val)) > MaxTagValueLength {
+			return nil, validationErrorf("tag %s value must be at most %d characters", key, MaxTagValueLength)
+		}
+		tags[key] = val
+	}
+	if len(tags) > MaxTags {
+		return nil, validationErrorf("at most %d tags are allowed", MaxTags)
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, nil
+}
+
+// ParseTagFilter splits a "key:value" list filter; a bare key matches any value
+func ParseTagFilter(filter string) (key, value string, err error) {
+	key, value, _ = strings.Cut(strings.TrimSpace(filter), ":")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !tagKeyRegex.MatchString(key) {
+		return "", "", validationErrorf("invalid tag filter %q", filter)
+	}
+	return key, value, nil
 }
 
 // hostnameRegex validates RFC 1123 hostnames
@@ -48,9 +185,157 @@ func ValidateHostname(hostname string) bool {
 	return hostnameRegex.MatchString(hostname)
 }
 
-// CreateDDNSRecord creates a new DDNS record
+// tldRegex matches a plausible top-level domain label
+var tldRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9\-]*[a-zA-Z0-9]$`)
+
+// ValidateFQDN validates that a hostname is a fully-qualified domain name:
+// a valid RFC 1123 hostname with at least two labels and a TLD-like final label
+func ValidateFQDN(hostname string) bool {
+	hostname = strings.TrimSuffix(hostname, ".")
+	if !ValidateHostname(hostname) {
+		return false
+	}
+
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	return tldRegex.MatchString(labels[len(labels)-1])
+}
+
+// CreateDDNSRecord creates a new DDNS record and a one-time reveal of its
+// token for config.Username. When config carries an idempotency key, a
+// repeat of a completed request reports the hostname as Replayed, with the
+// original reveal, instead of creating again.
 func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig) *CreateDDNSResult {
-	// Validate zone exists first (needed for auto-suffix)
+	key := config.IdempotencyKey
+	if key == "" {
+		return s.createAndReveal(ctx, config)
+	}
+
+	claimed, err := s.store.ClaimIdempotencyKey(ctx, config.Username, key)
+	if err != nil {
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   "Failed to check for a duplicate request",
+		}
+	}
+	if !claimed {
+		previous, err := s.store.GetIdempotencyRecord(ctx, config.Username, key)
+		if err == nil && previous != nil && previous.Completed {
+			return &CreateDDNSResult{
+				Success:  true,
+				Hostname: previous.Hostname,
+				RevealID: previous.RevealID,
+				Replayed: true,
+			}
+		}
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   "This request is already being processed",
+		}
+	}
+
+	result := s.createAndReveal(ctx, config)
+	if result.Success {
+		err = s.store.CompleteIdempotencyKey(ctx, config.Username, key, result.Hostname, result.RevealID)
+	} else {
+		err = s.store.ReleaseIdempotencyKey(ctx, config.Username, key)
+	}
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	return result
+}
+
+// createAndReveal creates the record and stores its token for a one-time
+// reveal. A failed reveal leaves RevealID empty and the token in the result.
+func (s *DDNSService) createAndReveal(ctx context.Context, config *DDNSConfig) *CreateDDNSResult {
+	result := s.createDDNSRecord(ctx, config)
+	if !result.Success {
+		return result
+	}
+
+	id, err := s.RevealToken(ctx, config.Username, result.Hostname, result.Token, false)
+	if err != nil {
+		fmt.Printf("Warning: Failed to store token reveal for %s: %v\n", result.Hostname, err)
+		return result
+	}
+	result.RevealID = id
+	return result
+}
+
+// ValidateDDNSInput checks every field of a new record at once and returns
+// an error message per invalid field, keyed by form field name, or nil when
+// all fields are valid
+func (s *DDNSService) ValidateDDNSInput(ctx context.Context, config *DDNSConfig) map[string]string {
+	errs := make(map[string]string)
+
+	var zoneName string
+	switch {
+	case config.ZoneID == "":
+		errs["zone_id"] = "Select a hosted zone"
+	case !route53.IsZoneAllowed(config.ZoneID):
+		errs["zone_id"] = "Zone is not allowed"
+	default:
+		zone, err := route53.GetZone(ctx, config.ZoneID)
+		if err != nil || zone == nil {
+			errs["zone_id"] = "Zone not found"
+		} else {
+			zoneName = zone.Name
+		}
+	}
+
+	// The zone suffix is added on create, so the full name can only be
+	// checked once the zone is known
+	hostname := strings.TrimSpace(config.Hostname)
+	switch {
+	case hostname == "":
+		errs["hostname"] = "Hostname is required"
+	case zoneName != "":
+		if !strings.HasSuffix(hostname, "."+zoneName) && hostname != zoneName {
+			hostname = hostname + "." + zoneName
+		}
+		if !ValidateHostname(hostname) {
+			errs["hostname"] = "Invalid hostname format"
+		}
+	}
+
+	if config.TTL != 0 && (config.TTL < MinTTL || config.TTL > MaxTTL) {
+		errs["ttl"] = fmt.Sprintf("TTL must be between %d and %d seconds", MinTTL, MaxTTL)
+	}
+
+	if config.InitialIP != "" && net.ParseIP(config.InitialIP) == nil {
+		errs["ip"] = "Invalid IP address format"
+	}
+
+	if _, err := normalizeDescription(config.Description); err != nil {
+		errs["description"] = err.Error()
+	}
+
+	if config.Token != "" {
+		if err := validateCustomToken(config.Token); err != nil {
+			errs["token"] = err.Error()
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// createDDNSRecord validates the config and creates the record
+func (s *DDNSService) createDDNSRecord(ctx context.Context, config *DDNSConfig) *CreateDDNSResult {
+	// Validate zone is allowed and exists first (needed for auto-suffix)
+	if !route53.IsZoneAllowed(config.ZoneID) {
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   "Zone is not allowed",
+		}
+	}
 	zone, err := route53.GetZone(ctx, config.ZoneID)
 	if err != nil || zone == nil {
 		return &CreateDDNSResult{
@@ -59,6 +344,23 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 		}
 	}
 
+	// A private zone is only visible inside its VPCs, so a public IP pushed
+	// by a home router won't resolve publicly
+	if zone.IsPrivate {
+		if RejectPrivateZones() {
+			return &CreateDDNSResult{
+				Success: false,
+				Error:   fmt.Sprintf("%s is a private hosted zone; DDNS records in private zones are disabled", zone.Name),
+			}
+		}
+		if !config.AllowPrivate {
+			return &CreateDDNSResult{
+				Success: false,
+				Error:   fmt.Sprintf("%s is a private hosted zone and only resolves inside its VPCs; confirm to create the record anyway", zone.Name),
+			}
+		}
+	}
+
 	// Auto-append zone suffix if hostname doesn't already include it
 	if !strings.HasSuffix(config.Hostname, "."+zone.Name) && config.Hostname != zone.Name {
 		config.Hostname = config.Hostname + "." + zone.Name
@@ -72,8 +374,17 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 		}
 	}
 
+	if config.Token != "" {
+		if err := validateCustomToken(config.Token); err != nil {
+			return &CreateDDNSResult{
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+	}
+
 	// Check if record already exists
-	existing, err := database.GetDDNSRecord(ctx, config.Hostname)
+	existing, err := s.store.GetDDNSRecord(ctx, config.Hostname)
 	if err != nil {
 		return &CreateDDNSResult{
 			Success: false,
@@ -87,12 +398,15 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 		}
 	}
 
-	// Generate update token
-	token, err := auth.GenerateUpdateToken()
-	if err != nil {
-		return &CreateDDNSResult{
-			Success: false,
-			Error:   "Failed to generate token",
+	// Generate an update token unless the user chose one
+	token := config.Token
+	if token == "" {
+		token, err = auth.GenerateUpdateToken()
+		if err != nil {
+			return &CreateDDNSResult{
+				Success: false,
+				Error:   "Failed to generate token",
+			}
 		}
 	}
 
@@ -108,7 +422,15 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 	// Set default TTL
 	ttl := config.TTL
 	if ttl <= 0 {
-		ttl = 60
+		ttl = DefaultTTL()
+	}
+
+	description, err := normalizeDescription(config.Description)
+	if err != nil {
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   err.Error(),
+		}
 	}
 
 	// Validate initial IP if provided
@@ -121,6 +443,32 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 		}
 	}
 
+	// Publishing is an UPSERT, so refuse to silently replace a record that
+	// was created outside DDNS unless the caller confirmed it. Without an
+	// initial IP the first client update would replace it instead.
+	if !config.Adopted && !config.Overwrite {
+		names := []string{config.Hostname}
+		if config.Wildcard {
+			names = append(names, WildcardName(config.Hostname))
+		}
+		for _, name := range names {
+			existing, err := existingRecord(ctx, config.ZoneID, name, config.InitialIP)
+			if err != nil {
+				return &CreateDDNSResult{
+					Success: false,
+					Error:   "Failed to check existing Route 53 record",
+				}
+			}
+			if existing != nil {
+				return &CreateDDNSResult{
+					Success: false,
+					Error:   fmt.Sprintf("A %s record for %s already exists in Route 53; confirm to overwrite it", existing.Type, name),
+					Err:     ErrRecordExists,
+				}
+			}
+		}
+	}
+
 	// Create the record
 	record := &database.DDNSRecord{
 		Hostname:        config.Hostname,
@@ -129,10 +477,13 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 		TTL:             ttl,
 		UpdateTokenHash: tokenHash,
 		CurrentIP:       config.InitialIP,
+		Description:     description,
+		Tags:            config.Tags,
 		Enabled:         true,
+		Wildcard:        config.Wildcard,
 	}
 
-	if err := database.CreateDDNSRecord(ctx, record); err != nil {
+	if err := s.store.CreateDDNSRecord(ctx, record); err != nil {
 		return &CreateDDNSResult{
 			Success: false,
 			Error:   "Failed to create record",
@@ -140,11 +491,16 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 	}
 
 	// If initial IP was provided, create the Route 53 record
-	if config.InitialIP != "" {
-		if err := route53.UpdateRecord(ctx, config.ZoneID, config.Hostname, config.InitialIP, ttl); err != nil {
+	if config.InitialIP != "" && !config.Adopted {
+		if err := publishRecord(ctx, record, config.Hostname, config.InitialIP); err != nil {
 			// Record was created in DB but Route 53 failed - not fatal
 			fmt.Printf("Warning: Failed to create initial Route 53 record: %v\n", err)
 		}
+		if record.Wildcard {
+			if err := publishRecord(ctx, record, WildcardName(config.Hostname), config.InitialIP); err != nil {
+				fmt.Printf("Warning: Failed to create initial wildcard Route 53 record: %v\n", err)
+			}
+		}
 	}
 
 	return &CreateDDNSResult{
@@ -156,64 +512,245 @@ func (s *DDNSService) CreateDDNSRecord(ctx context.Context, config *DDNSConfig)
 
 // GetDDNSRecord retrieves a DDNS record
 func (s *DDNSService) GetDDNSRecord(ctx context.Context, hostname string) (*database.DDNSRecord, error) {
-	return database.GetDDNSRecord(ctx, hostname)
+	return s.store.GetDDNSRecord(ctx, hostname)
 }
 
 // ListDDNSRecords lists all DDNS records
 func (s *DDNSService) ListDDNSRecords(ctx context.Context) ([]database.DDNSRecord, error) {
-	return database.ListDDNSRecords(ctx)
+	return s.store.ListDDNSRecords(ctx)
+}
+
+// ListDDNSRecordsByTag lists the DDNS records matching a "key:value" tag filter
+func (s *DDNSService) ListDDNSRecordsByTag(ctx context.Context, filter string) ([]database.DDNSRecord, error) {
+	key, value, err := ParseTagFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListDDNSRecordsByTag(ctx, key, value)
+}
+
+// AdoptDDNSRecord brings an existing A or AAAA record under DDNS management.
+// The record's live value and TTL are copied so the first update from the
+// client reports nochg, and Route 53 is left untouched.
+func (s *DDNSService) AdoptDDNSRecord(ctx context.Context, zoneID, name, recordType string) *CreateDDNSResult {
+	if recordType != "A" && recordType != "AAAA" {
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   "Only A and AAAA records can be adopted",
+		}
+	}
+
+	records, err := route53.ListRecords(ctx, zoneID)
+	if err != nil {
+		return &CreateDDNSResult{
+			Success: false,
+			Error:   "Failed to read zone records",
+		}
+	}
+
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, record := range records {
+		if !strings.EqualFold(record.Name, name) || record.Type != recordType {
+			continue
+		}
+		if record.Alias != nil || len(record.Values) != 1 {
+			return &CreateDDNSResult{
+				Success: false,
+				Error:   "Only records with a single IP value can be adopted",
+			}
+		}
+
+		return s.createDDNSRecord(ctx, &DDNSConfig{
+			Hostname:     name,
+			ZoneID:       zoneID,
+			TTL:          record.TTL,
+			InitialIP:    record.Values[0],
+			Description:  "Adopted from Route 53",
+			AllowPrivate: true,
+			Adopted:      true,
+		})
+	}
+
+	return &CreateDDNSResult{
+		Success: false,
+		Error:   fmt.Sprintf("No %s record found for %s", recordType, name),
+	}
+}
+
+// DDNSSettings represents the editable settings of a DDNS record
+type DDNSSettings struct {
+	Enabled           bool
+	TTL               int64
+	RateLimitPerHour  int      // zero restores the default limit
+	MinUpdateInterval int64    // seconds between DNS changes, zero uses the global cooldown
+	StaticValues      []string // additional IPs published alongside the dynamic one
+	ReverseZoneID     string   // reverse zone holding the PTR record, empty to disable
+	AllowedFamilies   string   // "v4", "v6" or "both"; see ParseAllowedFamilies
+	Description       string
+	Tags              map[string]string
+	WebhookURL        string // event webhook for this record, empty to use WEBHOOK_URL
+	WebhookSecret     string // signing secret for WebhookURL, empty to keep the current one
 }
 
 // UpdateDDNSRecord updates a DDNS record
-func (s *DDNSService) UpdateDDNSRecord(ctx context.Context, hostname string, enabled bool, ttl int64) error {
-	record, err := database.GetDDNSRecord(ctx, hostname)
+func (s *DDNSService) UpdateDDNSRecord(ctx context.Context, hostname string, settings *DDNSSettings) error {
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
 	if err != nil {
 		return err
 	}
 	if record == nil {
-		return fmt.Errorf("record not found")
+		return ErrRecordNotFound
 	}
 
-	record.Enabled = enabled
-	if ttl > 0 {
-		record.TTL = ttl
+	description, err := normalizeDescription(settings.Description)
+	if err != nil {
+		return err
+	}
+	if settings.RateLimitPerHour < 0 {
+		return fmt.Errorf("rate limit must not be negative")
+	}
+	if settings.MinUpdateInterval < 0 {
+		return fmt.Errorf("minimum update interval must not be negative")
+	}
+	families, err := ParseAllowedFamilies(settings.AllowedFamilies)
+	if err != nil {
+		return err
+	}
+	for _, value := range settings.StaticValues {
+		if net.ParseIP(value) == nil {
+			return fmt.Errorf("invalid static IP address: %s", value)
+		}
+	}
+	if settings.WebhookURL != "" {
+		if parsed, err := url.Parse(settings.WebhookURL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("webhook URL must be an http or https URL")
+		}
+	}
+
+	if settings.ReverseZoneID != "" && settings.ReverseZoneID != record.ReverseZoneID {
+		zone, err := route53.GetZone(ctx, settings.ReverseZoneID)
+		if err != nil || zone == nil {
+			return fmt.Errorf("reverse zone not found")
+		}
+		if !IsReverseZone(zone.Name) {
+			return fmt.Errorf("%s is not a reverse DNS zone", zone.Name)
+		}
+	}
+
+	// Route 53 must be republished when the published value set changes
+	republish := record.CurrentIP != "" &&
+		((settings.TTL > 0 && settings.TTL != record.TTL) || !equalValues(record.StaticValues, settings.StaticValues))
+
+	// Drop the old PTR while the record still matches what Route 53 holds
+	reverseChanged := settings.ReverseZoneID != record.ReverseZoneID
+	if reverseChanged {
+		deletePTR(ctx, record, record.CurrentIP)
+	}
+
+	record.Enabled = settings.Enabled
+	if settings.TTL > 0 {
+		record.TTL = settings.TTL
+	}
+	record.RateLimitPerHour = settings.RateLimitPerHour
+	record.MinUpdateInterval = settings.MinUpdateInterval
+	record.StaticValues = settings.StaticValues
+	record.ReverseZoneID = settings.ReverseZoneID
+	record.AllowedFamilies = families
+	record.Description = description
+	record.Tags = settings.Tags
+
+	// A record's secret only signs its own webhook
+	record.WebhookURL = settings.WebhookURL
+	if settings.WebhookURL == "" {
+		record.WebhookSecret = ""
+	} else if settings.WebhookSecret != "" {
+		record.WebhookSecret = settings.WebhookSecret
+	}
+
+	if republish {
+		if err := publishRecord(ctx, record, hostname, record.CurrentIP); err != nil {
+			return fmt.Errorf("failed to update DNS record: %w", err)
+		}
+		if record.Wildcard {
+			if err := publishRecord(ctx, record, WildcardName(hostname), record.CurrentIP); err != nil {
+				return fmt.Errorf("failed to update wildcard DNS record: %w", err)
+			}
+		}
+	}
+	if (republish || reverseChanged) && record.CurrentIP != "" {
+		if err := updatePTR(ctx, record, "", record.CurrentIP); err != nil {
+			return fmt.Errorf("failed to update PTR record: %w", err)
+		}
 	}
 
-	return database.UpdateDDNSRecord(ctx, record)
+	return s.store.UpdateDDNSRecord(ctx, record)
 }
 
 // DeleteDDNSRecord deletes a DDNS record and its Route 53 record
 func (s *DDNSService) DeleteDDNSRecord(ctx context.Context, hostname string) error {
-	record, err := database.GetDDNSRecord(ctx, hostname)
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
 	if err != nil {
 		return err
 	}
 	if record == nil {
-		return fmt.Errorf("record not found")
+		return ErrRecordNotFound
 	}
 
-	// Delete Route 53 record if IP exists
+	// Delete Route 53 record if IP exists. A record set that is already gone
+	// must not block the cleanup, but any other failure leaves the database
+	// entry in place so the delete can be retried.
 	if record.CurrentIP != "" {
-		_ = route53.DeleteRecord(ctx, record.ZoneID, hostname, record.CurrentIP, record.TTL)
+		if err := unpublishIfPresent(ctx, record, hostname); err != nil {
+			return err
+		}
+		if record.Wildcard {
+			if err := unpublishIfPresent(ctx, record, WildcardName(hostname)); err != nil {
+				return err
+			}
+		}
+		deletePTR(ctx, record, record.CurrentIP)
+	}
+	if err := unpublishMX(ctx, record); err != nil {
+		return err
+	}
+	if err := removeCNAME(ctx, record); err != nil {
+		return err
+	}
+	if record.TXT != "" {
+		if err := unpublishLive(ctx, record, hostname, types.RRTypeTxt); err != nil {
+			return err
+		}
 	}
 
-	return database.DeleteDDNSRecord(ctx, hostname)
+	InvalidateTokenCache(hostname)
+	removeHostnameFromTokens(ctx, s.store, hostname)
+	return s.store.DeleteDDNSRecord(ctx, hostname)
 }
 
-// RegenerateToken generates a new token for a DDNS record
-func (s *DDNSService) RegenerateToken(ctx context.Context, hostname string) (string, error) {
-	record, err := database.GetDDNSRecord(ctx, hostname)
+// RegenerateToken replaces a DDNS record's token with token, or with a newly
+// generated one when token is empty
+func (s *DDNSService) RegenerateToken(ctx context.Context, hostname, token string, expiresIn time.Duration) (string, error) {
+	if expiresIn < 0 {
+		return "", validationErrorf("token expiry must not be negative")
+	}
+	if token != "" {
+		if err := validateCustomToken(token); err != nil {
+			return "", err
+		}
+	}
+
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
 	if err != nil {
 		return "", err
 	}
 	if record == nil {
-		return "", fmt.Errorf("record not found")
+		return "", ErrRecordNotFound
 	}
 
-	// Generate new token
-	token, err := auth.GenerateUpdateToken()
-	if err != nil {
-		return "", err
+	if token == "" {
+		if token, err = auth.GenerateUpdateToken(); err != nil {
+			return "", err
+		}
 	}
 
 	// Hash and store
@@ -223,9 +760,14 @@ func (s *DDNSService) RegenerateToken(ctx context.Context, hostname string) (str
 	}
 
 	record.UpdateTokenHash = tokenHash
-	if err := database.UpdateDDNSRecord(ctx, record); err != nil {
+	record.TokenExpiresAt = time.Time{}
+	if expiresIn > 0 {
+		record.TokenExpiresAt = time.Now().UTC().Add(expiresIn)
+	}
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
 		return "", err
 	}
+	InvalidateTokenCache(hostname)
 
 	return token, nil
 }
@@ -236,29 +778,377 @@ func (s *DDNSService) ManualUpdateIP(ctx context.Context, hostname, ip string) e
 		return fmt.Errorf("invalid IP address format")
 	}
 
-	record, err := database.GetDDNSRecord(ctx, hostname)
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
 	if err != nil {
 		return err
 	}
 	if record == nil {
-		return fmt.Errorf("record not found")
+		return ErrRecordNotFound
 	}
 
-	// Update Route 53 record
-	if err := route53.UpdateRecord(ctx, record.ZoneID, hostname, ip, record.TTL); err != nil {
+	// Update Route 53 record, replacing any CNAME
+	if err := removeCNAME(ctx, record); err != nil {
+		return fmt.Errorf("failed to delete CNAME record: %w", err)
+	}
+	if err := publishRecord(ctx, record, hostname, ip); err != nil {
 		return fmt.Errorf("failed to update DNS record: %w", err)
 	}
+	if record.Wildcard {
+		if err := publishRecord(ctx, record, WildcardName(hostname), ip); err != nil {
+			return fmt.Errorf("failed to update wildcard DNS record: %w", err)
+		}
+	}
 
-	// Update database record
+	if err := updatePTR(ctx, record, record.CurrentIP, ip); err != nil {
+		fmt.Printf("Warning: Failed to update PTR record: %v\n", err)
+	}
+
+	// Update database record; a manual change supersedes any deferred one
+	if record.CurrentIP != ip {
+		record.PreviousIP = record.CurrentIP
+	}
 	record.CurrentIP = ip
-	if err := database.UpdateDDNSRecord(ctx, record); err != nil {
+	record.PendingIP = ""
+	record.IPChangedAt = time.Now().UTC()
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
 		return fmt.Errorf("failed to update database record: %w", err)
 	}
 
 	return nil
 }
 
+// ForceRepublish pushes the stored IP to Route 53 even though it has not
+// changed, reasserting the database as authoritative after a console edit or
+// other drift. The wildcard, MX and PTR records are republished too.
+func (s *DDNSService) ForceRepublish(ctx context.Context, hostname, sourceIP string) error {
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrRecordNotFound
+	}
+	if record.CurrentIP == "" && record.CNAME == "" {
+		return validationErrorf("record has no IP address to publish")
+	}
+
+	if record.CurrentIP != "" {
+		if err := publishRecord(ctx, record, hostname, record.CurrentIP); err != nil {
+			return fmt.Errorf("failed to update DNS record: %w", err)
+		}
+		if record.Wildcard {
+			if err := publishRecord(ctx, record, WildcardName(hostname), record.CurrentIP); err != nil {
+				return fmt.Errorf("failed to update wildcard DNS record: %w", err)
+			}
+		}
+	}
+	if record.CNAME != "" {
+		if err := publishCNAME(ctx, record); err != nil {
+			return fmt.Errorf("failed to update CNAME record: %w", err)
+		}
+	}
+	if record.TXT != "" {
+		if err := route53.UpsertRecord(ctx, record.ZoneID, hostname, types.RRTypeTxt, record.TXT, EffectiveTTL(record)); err != nil {
+			return fmt.Errorf("failed to update TXT record: %w", err)
+		}
+	}
+	if record.MX != "" {
+		if err := publishMX(ctx, record); err != nil {
+			return fmt.Errorf("failed to update MX record: %w", err)
+		}
+	}
+	if record.CurrentIP != "" {
+		if err := updatePTR(ctx, record, record.CurrentIP, record.CurrentIP); err != nil {
+			fmt.Printf("Warning: Failed to update PTR record: %v\n", err)
+		}
+	}
+
+	writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+		PreviousIP: record.CurrentIP,
+		NewIP:      record.CurrentIP,
+		SourceIP:   sourceIP,
+		UserAgent:  "admin",
+		Wildcard:   record.Wildcard,
+		Status:     "forced",
+	})
+
+	return nil
+}
+
+// PauseUpdates blocks DDNS updates for a hostname for the given duration
+func (s *DDNSService) PauseUpdates(ctx context.Context, hostname string, duration time.Duration) error {
+	if duration <= 0 {
+		return fmt.Errorf("pause duration must be positive")
+	}
+
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrRecordNotFound
+	}
+
+	record.PausedUntil = time.Now().UTC().Add(duration)
+	return s.store.UpdateDDNSRecord(ctx, record)
+}
+
+// ResumeUpdates clears any pause on a hostname
+func (s *DDNSService) ResumeUpdates(ctx context.Context, hostname string) error {
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrRecordNotFound
+	}
+
+	record.PausedUntil = time.Time{}
+	return s.store.UpdateDDNSRecord(ctx, record)
+}
+
 // GetUpdateHistory retrieves update history for a hostname
 func (s *DDNSService) GetUpdateHistory(ctx context.Context, hostname string, limit int32) ([]database.UpdateLog, error) {
-	return database.GetUpdateLogs(ctx, hostname, limit)
+	return s.store.GetUpdateLogs(ctx, hostname, limit)
+}
+
+// IPChange is a published IP change, shaped for a client-side timeline
+type IPChange struct {
+	Timestamp  time.Time `json:"timestamp"`
+	PreviousIP string    `json:"previous_ip"`
+	NewIP      string    `json:"new_ip"`
+	Status     string    `json:"status"`
+}
+
+// MaxHistoryLimit caps how many log entries a history request may scan
+const MaxHistoryLimit = 500
+
+// GetIPChanges returns the address updates among a hostname's latest limit
+// log entries that actually changed the published IP, oldest first. Entries
+// before since are dropped when it is set.
+func (s *DDNSService) GetIPChanges(ctx context.Context, hostname string, limit int32, since time.Time) ([]IPChange, error) {
+	logs, err := s.store.GetUpdateLogs(ctx, hostname, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]IPChange, 0, len(logs))
+	for i := len(logs) - 1; i >= 0; i-- {
+		entry := logs[i]
+		// CNAME, TXT and MX updates log values, not addresses
+		if entry.Status != "success" || entry.RecordType != "" || entry.PreviousIP == entry.NewIP {
+			continue
+		}
+		if !since.IsZero() && entry.Timestamp.Before(since) {
+			continue
+		}
+		changes = append(changes, IPChange{
+			Timestamp:  entry.Timestamp,
+			PreviousIP: entry.PreviousIP,
+			NewIP:      entry.NewIP,
+			Status:     entry.Status,
+		})
+	}
+	return changes, nil
+}
+
+// LogFilter narrows an update log export. Zero values match everything.
+type LogFilter struct {
+	Status string
+	Since  time.Time
+	Until  time.Time
+}
+
+// matches reports whether a log entry passes the filter
+func (f LogFilter) matches(entry *database.UpdateLog) bool {
+	if f.Status != "" && entry.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ExportUpdateLogs calls fn with every update log for a hostname that passes
+// the filter, newest first. It pages through the full history rather than
+// the latest entries shown on the detail page.
+func (s *DDNSService) ExportUpdateLogs(ctx context.Context, hostname string, filter LogFilter, fn func(*database.UpdateLog) error) error {
+	var fnErr error
+	err := s.store.ForEachUpdateLog(ctx, hostname, func(logs []database.UpdateLog) bool {
+		for i := range logs {
+			if !filter.Since.IsZero() && logs[i].Timestamp.Before(filter.Since) {
+				return false // logs are newest first
+			}
+			if !filter.matches(&logs[i]) {
+				continue
+			}
+			if fnErr = fn(&logs[i]); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// DDNSSummary aggregates record counts and recent activity for the dashboard
+type DDNSSummary struct {
+	TotalRecords    int
+	EnabledRecords  int
+	UpdatedRecently int // records updated within SummaryWindow
+	FailedAuth      int // badauth updates within SummaryWindow
+	Abuse           int // rate-limited or blocked updates within SummaryWindow
+	RecentlyUpdated []database.DDNSRecord
+}
+
+// SummaryWindow is the period counted as "recent" on the dashboard
+const SummaryWindow = 24 * time.Hour
+
+// summaryBucket is the granularity of the refused-update counts behind the
+// summary, which may include up to one bucket before SummaryWindow
+const summaryBucket = time.Hour
+
+// summaryCountKey is the rate limit key counting refusals of one kind in the
+// bucket holding t
+func summaryCountKey(status string, t time.Time) string {
+	return fmt.Sprintf("summary:%s:%d", status, t.Truncate(summaryBucket).Unix())
+}
+
+// countRefusedUpdate adds a refused update to the summary counts. status is
+// ResponseBadAuth or ResponseAbuse.
+func countRefusedUpdate(ctx context.Context, store database.Store, status string) {
+	window := int64((SummaryWindow + summaryBucket).Seconds())
+	key := summaryCountKey(status, time.Now().UTC())
+	if _, _, err := store.IncrementRateLimit(ctx, key, math.MaxInt32, window); err != nil {
+		fmt.Printf("Warning: Failed to count refused update: %v\n", err)
+	}
+}
+
+// refusedUpdates sums the refusals of one kind counted since since
+func (s *DDNSService) refusedUpdates(ctx context.Context, status string, since time.Time) (int, error) {
+	total := 0
+	now := time.Now().UTC()
+	for t := since.Truncate(summaryBucket); !t.After(now); t = t.Add(summaryBucket) {
+		count, err := s.store.GetRateLimitCount(ctx, summaryCountKey(status, t))
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// Summary aggregates record counts and recent update activity
+func (s *DDNSService) Summary(ctx context.Context, recentCount int) (*DDNSSummary, error) {
+	records, err := s.store.ListDDNSRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().UTC().Add(-SummaryWindow)
+	summary := &DDNSSummary{TotalRecords: len(records)}
+	for _, record := range records {
+		if record.Enabled {
+			summary.EnabledRecords++
+		}
+		if record.LastUpdated.After(since) {
+			summary.UpdatedRecently++
+		}
+	}
+
+	// Refusals are counted as they happen rather than read back from every
+	// record's update log
+	if summary.FailedAuth, err = s.refusedUpdates(ctx, ResponseBadAuth, since); err != nil {
+		return nil, err
+	}
+	if summary.Abuse, err = s.refusedUpdates(ctx, ResponseAbuse, since); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastUpdated.After(records[j].LastUpdated)
+	})
+	if len(records) > recentCount {
+		records = records[:recentCount]
+	}
+	summary.RecentlyUpdated = records
+
+	return summary, nil
+}
+
+// RecordValues returns the values published for a record when its dynamic IP
+// is ip: the dynamic IP followed by any static values of the same family
+func RecordValues(record *database.DDNSRecord, ip string) []string {
+	values := []string{ip}
+	isV4 := net.ParseIP(ip).To4() != nil
+	for _, value := range record.StaticValues {
+		parsed := net.ParseIP(value)
+		if parsed == nil || value == ip || (parsed.To4() != nil) != isV4 {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// publishRecord upserts the Route 53 record set for name with the record's full value set
+func publishRecord(ctx context.Context, record *database.DDNSRecord, name, ip string) error {
+	return route53.UpsertRecordValues(ctx, record.ZoneID, name, route53.RecordTypeForIP(ip), RecordValues(record, ip), EffectiveTTL(record))
+}
+
+// unpublishRecord deletes the Route 53 record set for name, rebuilding the
+// full value set so it matches what was stored
+func unpublishRecord(ctx context.Context, record *database.DDNSRecord, name, ip string) error {
+	return route53.DeleteRecordValues(ctx, record.ZoneID, name, route53.RecordTypeForIP(ip), RecordValues(record, ip), EffectiveTTL(record))
+}
+
+// unpublishIfPresent deletes the Route 53 record set for name, treating a
+// record set that no longer exists as already deleted
+func unpublishIfPresent(ctx context.Context, record *database.DDNSRecord, name string) error {
+	err := unpublishRecord(ctx, record, name, record.CurrentIP)
+	if route53.IsRecordNotFound(err) {
+		fmt.Printf("Warning: Route 53 record %s was already deleted\n", name)
+		return nil
+	}
+	return err
+}
+
+// existingRecord returns an address or CNAME record Route 53 already holds at
+// name that a DDNS record would replace, or nil when there is none. A record
+// already publishing exactly ip is not a conflict.
+func existingRecord(ctx context.Context, zoneID, name, ip string) (*route53.Record, error) {
+	for _, recordType := range []types.RRType{types.RRTypeA, types.RRTypeAaaa, types.RRTypeCname} {
+		existing, err := route53.GetRecord(ctx, zoneID, name, recordType)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			continue
+		}
+		if ip != "" && recordType == route53.RecordTypeForIP(ip) && equalValues(existing.Values, []string{ip}) {
+			continue
+		}
+		return existing, nil
+	}
+	return nil, nil
+}
+
+// equalValues reports whether two value lists hold the same values in order
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
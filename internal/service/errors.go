@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by services that callers may map to specific responses
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrRecordExists   = errors.New("record already exists")
+	ErrZoneNotFound   = errors.New("zone not found")
+)
+
+// ValidationError reports invalid input supplied by the caller
+type ValidationError struct {
+	Message string
+}
+
+// Error returns the validation message
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// validationErrorf formats a ValidationError
+func validationErrorf(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
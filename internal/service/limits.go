@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+)
+
+// LimitsService inspects and resets rate-limit and lockout state for support
+type LimitsService struct {
+	store database.Store
+}
+
+// NewLimitsService creates a new limits service
+func NewLimitsService() *LimitsService {
+	return &LimitsService{store: database.GetStore()}
+}
+
+// RateLimitState is the live counter behind one of a hostname's limits
+type RateLimitState struct {
+	Label     string
+	Count     int
+	Limit     int
+	WindowEnd time.Time
+	Exceeded  bool
+}
+
+// HostnameRateLimits returns the active counters for a hostname's update,
+// nochg, badauth-alert, lockout and flapping limits. Counters without an active window are omitted.
+func (s *LimitsService) HostnameRateLimits(ctx context.Context, hostname string) ([]RateLimitState, error) {
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := []struct {
+		label string
+		key   string
+		limit int
+	}{
+		{"IP changes", updateRateLimitKey(hostname), UpdateRateLimit(record)},
+		{"nochg pings", noChgRateLimitKey(hostname), NoChgRateLimit(record)},
+		{"badauth alerts", badAuthAlertKey(hostname), BadAuthAlertThreshold},
+		{"failed token checks", updateLockoutKey(hostname), UpdateLockoutThreshold},
+		{"IP flips", flapKey(hostname), FlapThreshold},
+	}
+
+	var states []RateLimitState
+	for _, l := range limits {
+		entry, err := s.store.GetRateLimitEntry(ctx, l.key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		states = append(states, RateLimitState{
+			Label:     l.label,
+			Count:     entry.Count,
+			Limit:     l.limit,
+			WindowEnd: time.Unix(entry.WindowEnd, 0).UTC(),
+			Exceeded:  entry.Count > l.limit,
+		})
+	}
+
+	return states, nil
+}
+
+// ClearHostnameRateLimits resets all of a hostname's rate limit counters
+func (s *LimitsService) ClearHostnameRateLimits(ctx context.Context, hostname string) error {
+	for _, key := range []string{updateRateLimitKey(hostname), noChgRateLimitKey(hostname), badAuthAlertKey(hostname), updateLockoutKey(hostname), flapKey(hostname)} {
+		if err := s.store.DeleteRateLimit(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IPLockoutAlert returns the lockout alert window recorded for a client IP, or
+// nil when none is active. Lockouts themselves are keyed by username; this
+// entry only shows that an IP triggered one recently.
+func (s *LimitsService) IPLockoutAlert(ctx context.Context, clientIP string) (*RateLimitState, error) {
+	entry, err := s.store.GetRateLimitEntry(ctx, lockoutAlertKey(clientIP))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return &RateLimitState{
+		Label:     "lockout alerts",
+		Count:     entry.Count,
+		Limit:     1,
+		WindowEnd: time.Unix(entry.WindowEnd, 0).UTC(),
+		Exceeded:  entry.Count > 1,
+	}, nil
+}
+
+// ClearIPLockoutAlert resets the lockout alert window for a client IP
+func (s *LimitsService) ClearIPLockoutAlert(ctx context.Context, clientIP string) error {
+	return s.store.DeleteRateLimit(ctx, lockoutAlertKey(clientIP))
+}
+
+// LoginLockout returns the failed-login state for a username from a client IP
+func (s *LimitsService) LoginLockout(ctx context.Context, username, clientIP string) (*database.LoginAttempt, error) {
+	return s.store.GetLoginAttempt(ctx, LoginAttemptKey(username, clientIP))
+}
+
+// ClearLoginLockout resets a username's failed logins from a client IP and
+// lifts any lockout
+func (s *LimitsService) ClearLoginLockout(ctx context.Context, username, clientIP string) error {
+	return s.store.ClearLoginAttempts(ctx, LoginAttemptKey(username, clientIP))
+}
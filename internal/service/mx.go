@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// DefaultMXPriority is used when the mx parameter names only a host
+const DefaultMXPriority = 10
+
+// backupMXPriority ranks the backup mail exchanger after the client's own
+const backupMXPriority = 50
+
+// BackupMXHost returns BACKUP_MX_HOST, the mail exchanger published at a
+// lower priority for clients that send backmx=YES. Empty disables backmx.
+func BackupMXHost() string {
+	return strings.TrimSuffix(os.Getenv("BACKUP_MX_HOST"), ".")
+}
+
+// ParseMX validates a DynDNS2 mx parameter, either "host" or "priority host",
+// and returns it normalized as "priority host"
+func ParseMX(value string) (string, error) {
+	fields := strings.Fields(value)
+	priority := DefaultMXPriority
+	switch len(fields) {
+	case 1:
+	case 2:
+		p, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return "", validationErrorf("mx priority must be between 0 and 65535")
+		}
+		priority = int(p)
+	default:
+		return "", validationErrorf("mx must be a hostname, optionally preceded by a priority")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(fields[len(fields)-1], "."))
+	if !ValidateFQDN(host) {
+		return "", validationErrorf("mx %q is not a valid hostname", host)
+	}
+	return fmt.Sprintf("%d %s", priority, host), nil
+}
+
+// mxValues returns the MX record values for a record's mail exchanger and
+// backup setting
+func mxValues(record *database.DDNSRecord) []string {
+	values := []string{record.MX + "."}
+	if backup := BackupMXHost(); record.BackMX && backup != "" {
+		values = append(values, fmt.Sprintf("%d %s.", backupMXPriority, backup))
+	}
+	return values
+}
+
+// publishMX upserts the hostname's MX record set
+func publishMX(ctx context.Context, record *database.DDNSRecord) error {
+	return route53.UpsertRecordValues(ctx, record.ZoneID, record.Hostname, types.RRTypeMx, mxValues(record), EffectiveTTL(record))
+}
+
+// unpublishMX deletes the hostname's MX record set, if one was published.
+// The live record set is read back first so a changed TTL or backup host
+// can't make the delete mismatch.
+func unpublishMX(ctx context.Context, record *database.DDNSRecord) error {
+	if record.MX == "" {
+		return nil
+	}
+	return unpublishLive(ctx, record, record.Hostname, types.RRTypeMx)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dynamic-route-53-dns/internal/database"
+)
+
+func TestOfflineDeletesExactAndWildcardRecords(t *testing.T) {
+	ctx := context.Background()
+	fake := useFakeRoute53(t)
+	store := database.NewMemoryStore()
+	s := &UpdateService{store: store}
+
+	tokenHash, err := HashToken("update-token")
+	if err != nil {
+		t.Fatalf("HashToken: %v", err)
+	}
+	if err := store.CreateDDNSRecord(ctx, &database.DDNSRecord{
+		PK:              "DDNS",
+		SK:              "home.example.com",
+		Hostname:        "home.example.com",
+		ZoneID:          "Z1",
+		ZoneName:        "example.com",
+		CurrentIP:       "198.51.100.1",
+		TTL:             60,
+		UpdateTokenHash: tokenHash,
+		Enabled:         true,
+		Wildcard:        true,
+	}); err != nil {
+		t.Fatalf("CreateDDNSRecord: %v", err)
+	}
+
+	request := &UpdateRequest{
+		Hostname: "home.example.com",
+		Token:    "update-token",
+		SourceIP: "198.51.100.2",
+		Offline:  true,
+	}
+	result := s.ProcessUpdate(ctx, request)
+	if result.Code != ResponseGood {
+		t.Fatalf("offline update = %s (%s); want good", result.Code, result.Message)
+	}
+	if len(fake.changes) != 2 {
+		t.Fatalf("Route 53 changes = %d; want deletes of the exact and wildcard records", len(fake.changes))
+	}
+	for i, name := range []string{"<Name>home.example.com.</Name>", "<Name>*.home.example.com.</Name>"} {
+		if !strings.Contains(fake.changes[i], "<Action>DELETE</Action>") || !strings.Contains(fake.changes[i], name) {
+			t.Errorf("change %d = %q; want a DELETE of %s", i, fake.changes[i], name)
+		}
+	}
+
+	record, err := store.GetDDNSRecord(ctx, "home.example.com")
+	if err != nil || record == nil {
+		t.Fatalf("GetDDNSRecord = %v, %v", record, err)
+	}
+	if record.CurrentIP != "" || record.PreviousIP != "198.51.100.1" || !record.Wildcard {
+		t.Errorf("record = current %q previous %q wildcard %v; want offline with the wildcard setting kept",
+			record.CurrentIP, record.PreviousIP, record.Wildcard)
+	}
+
+	// Going offline again changes nothing
+	if result := s.ProcessUpdate(ctx, request); result.Code != ResponseNoChg {
+		t.Errorf("second offline update = %s; want nochg", result.Code)
+	}
+	if len(fake.changes) != 2 {
+		t.Errorf("second offline update sent %d more changes", len(fake.changes)-2)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsroute53 "github.com/aws/aws-sdk-go-v2/service/route53"
+)
+
+// fakeRoute53 accepts every change batch and records the request bodies
+type fakeRoute53 struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (f *fakeRoute53) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	f.mu.Lock()
+	f.changes = append(f.changes, string(body))
+	f.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body: io.NopCloser(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<ChangeResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status><SubmittedAt>2026-01-01T00:00:00Z</SubmittedAt></ChangeInfo>
+</ChangeResourceRecordSetsResponse>`)),
+		Request: req,
+	}, nil
+}
+
+// useFakeRoute53 points the Route 53 client at a fake for the test
+func useFakeRoute53(t *testing.T) *fakeRoute53 {
+	t.Helper()
+	fake := &fakeRoute53{}
+	previous := route53.GetClient()
+	route53.SetClient(awsroute53.New(awsroute53.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  fake,
+	}))
+	t.Cleanup(func() { route53.SetClient(previous) })
+	return fake
+}
+
+func TestDeferredAddressIsPublishedAfterCooldown(t *testing.T) {
+	ctx := context.Background()
+	fake := useFakeRoute53(t)
+	store := database.NewMemoryStore()
+	s := &UpdateService{store: store}
+
+	tokenHash, err := HashToken("update-token")
+	if err != nil {
+		t.Fatalf("HashToken: %v", err)
+	}
+	if err := store.CreateDDNSRecord(ctx, &database.DDNSRecord{
+		PK:                "DDNS",
+		SK:                "home.example.com",
+		Hostname:          "home.example.com",
+		ZoneID:            "Z1",
+		ZoneName:          "example.com",
+		CurrentIP:         "198.51.100.1",
+		TTL:               60,
+		UpdateTokenHash:   tokenHash,
+		Enabled:           true,
+		MinUpdateInterval: 300,
+		IPChangedAt:       time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("CreateDDNSRecord: %v", err)
+	}
+
+	// Inside the cooldown the new address is only stored as pending
+	result := s.ProcessUpdate(ctx, &UpdateRequest{
+		Hostname: "home.example.com",
+		Token:    "update-token",
+		IP:       "198.51.100.2",
+		SourceIP: "198.51.100.2",
+	})
+	if result.Code != ResponseNoChg || result.IP != "198.51.100.1" {
+		t.Fatalf("update in cooldown = %s %s; want nochg 198.51.100.1", result.Code, result.IP)
+	}
+	if len(fake.changes) != 0 {
+		t.Fatalf("deferred update sent %d changes to Route 53; want none", len(fake.changes))
+	}
+
+	// Nothing is published while the cooldown holds
+	if published, err := s.PublishPending(ctx, time.Now().UTC()); err != nil || published != 0 {
+		t.Fatalf("PublishPending in cooldown = %d, %v; want 0", published, err)
+	}
+
+	published, err := s.PublishPending(ctx, time.Now().UTC().Add(10*time.Minute))
+	if err != nil || published != 1 {
+		t.Fatalf("PublishPending after cooldown = %d, %v; want 1", published, err)
+	}
+	if len(fake.changes) != 1 || !strings.Contains(fake.changes[0], "198.51.100.2") {
+		t.Fatalf("Route 53 changes = %q; want one upsert of 198.51.100.2", fake.changes)
+	}
+
+	record, err := store.GetDDNSRecord(ctx, "home.example.com")
+	if err != nil || record == nil {
+		t.Fatalf("GetDDNSRecord = %v, %v", record, err)
+	}
+	if record.CurrentIP != "198.51.100.2" || record.PendingIP != "" || record.PreviousIP != "198.51.100.1" {
+		t.Errorf("record = current %q pending %q previous %q; want the pending address published",
+			record.CurrentIP, record.PendingIP, record.PreviousIP)
+	}
+}
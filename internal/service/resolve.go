@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+// DefaultDNSResolver is the public resolver used when DNS_RESOLVER is not set
+const DefaultDNSResolver = "1.1.1.1:53"
+
+// resolveTimeout bounds a propagation lookup
+const resolveTimeout = 3 * time.Second
+
+// ResolveResult describes what a hostname resolves to on a public resolver
+type ResolveResult struct {
+	Resolver  string
+	Addresses []string
+	Matches   bool   // the stored IP is among the resolved addresses
+	Error     string // set when the lookup failed
+}
+
+// DNSResolver returns the resolver address from DNS_RESOLVER, adding port 53 if omitted
+func DNSResolver() string {
+	resolver := os.Getenv("DNS_RESOLVER")
+	if resolver == "" {
+		return DefaultDNSResolver
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		return net.JoinHostPort(resolver, "53")
+	}
+	return resolver
+}
+
+// CheckResolution looks up hostname's A and AAAA records on the configured
+// public resolver and compares them with expectedIP
+func CheckResolution(ctx context.Context, hostname, expectedIP string) *ResolveResult {
+	address := DNSResolver()
+	result := &ResolveResult{Resolver: address}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	ips, err := resolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			result.Error = "Hostname does not resolve (NXDOMAIN)"
+		case errors.As(err, &dnsErr) && dnsErr.IsTimeout, errors.Is(err, context.DeadlineExceeded):
+			result.Error = "Lookup timed out"
+		default:
+			result.Error = "Lookup failed: " + err.Error()
+		}
+		return result
+	}
+
+	expected := net.ParseIP(expectedIP)
+	for _, ip := range ips {
+		result.Addresses = append(result.Addresses, ip.IP.String())
+		if expected != nil && ip.IP.Equal(expected) {
+			result.Matches = true
+		}
+	}
+	sort.Strings(result.Addresses)
+
+	return result
+}
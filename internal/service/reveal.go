@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"dynamic-route-53-dns/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// RevealToken stores a newly issued update token so username can view it
+// until they acknowledge saving it, and returns the reveal ID
+func (s *DDNSService) RevealToken(ctx context.Context, username, hostname, token string, regenerated bool) (string, error) {
+	id := uuid.New().String()
+	err := s.store.CreateTokenReveal(ctx, &database.TokenReveal{
+		SK:          id,
+		Hostname:    hostname,
+		Username:    username,
+		Token:       token,
+		Regenerated: regenerated,
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetTokenReveal returns username's reveal of hostname's token, or nil when
+// it has expired or belongs to another user or hostname
+func (s *DDNSService) GetTokenReveal(ctx context.Context, id, username, hostname string) (*database.TokenReveal, error) {
+	reveal, err := s.store.GetTokenReveal(ctx, id)
+	if err != nil || reveal == nil {
+		return nil, err
+	}
+	if reveal.Username != username || reveal.Hostname != hostname {
+		return nil, nil
+	}
+	return reveal, nil
+}
+
+// AcknowledgeToken records that username has saved the revealed token, after
+// which it is no longer shown
+func (s *DDNSService) AcknowledgeToken(ctx context.Context, id, username, hostname string) error {
+	reveal, err := s.GetTokenReveal(ctx, id, username, hostname)
+	if err != nil || reveal == nil || reveal.Acknowledged {
+		return err
+	}
+	return s.store.AcknowledgeTokenReveal(ctx, id)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"dynamic-route-53-dns/internal/database"
+)
+
+func TestTokenRevealIsShownUntilAcknowledged(t *testing.T) {
+	ctx := context.Background()
+	s := &DDNSService{store: database.NewMemoryStore()}
+
+	id, err := s.RevealToken(ctx, "admin", "home.example.com", "secret-token", false)
+	if err != nil {
+		t.Fatalf("RevealToken: %v", err)
+	}
+
+	reveal, err := s.GetTokenReveal(ctx, id, "admin", "home.example.com")
+	if err != nil || reveal == nil {
+		t.Fatalf("GetTokenReveal = %v, %v; want the reveal", reveal, err)
+	}
+	if reveal.Token != "secret-token" || reveal.Acknowledged {
+		t.Errorf("reveal = %+v; want the unacknowledged token", reveal)
+	}
+
+	if err := s.AcknowledgeToken(ctx, id, "admin", "home.example.com"); err != nil {
+		t.Fatalf("AcknowledgeToken: %v", err)
+	}
+	reveal, err = s.GetTokenReveal(ctx, id, "admin", "home.example.com")
+	if err != nil || reveal == nil {
+		t.Fatalf("GetTokenReveal after acknowledging = %v, %v; want the reveal", reveal, err)
+	}
+	if reveal.Token != "" || !reveal.Acknowledged {
+		t.Errorf("reveal after acknowledging = %+v; want no token", reveal)
+	}
+}
+
+func TestTokenRevealIsScopedToUserAndHostname(t *testing.T) {
+	ctx := context.Background()
+	s := &DDNSService{store: database.NewMemoryStore()}
+
+	id, err := s.RevealToken(ctx, "admin", "home.example.com", "secret-token", false)
+	if err != nil {
+		t.Fatalf("RevealToken: %v", err)
+	}
+
+	for _, tc := range []struct{ username, hostname string }{
+		{"other", "home.example.com"},
+		{"admin", "office.example.com"},
+	} {
+		reveal, err := s.GetTokenReveal(ctx, id, tc.username, tc.hostname)
+		if err != nil || reveal != nil {
+			t.Errorf("GetTokenReveal(%s, %s) = %v, %v; want nil", tc.username, tc.hostname, reveal, err)
+		}
+	}
+
+	// Another user's acknowledgment must not hide the token
+	if err := s.AcknowledgeToken(ctx, id, "other", "home.example.com"); err != nil {
+		t.Fatalf("AcknowledgeToken: %v", err)
+	}
+	reveal, _ := s.GetTokenReveal(ctx, id, "admin", "home.example.com")
+	if reveal == nil || reveal.Acknowledged {
+		t.Errorf("reveal = %+v; want it still unacknowledged", reveal)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name for an IP address
+func ReverseName(ip string) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+
+	// IPv6 uses one label per nibble, least significant first
+	const hexDigits = "0123456789abcdef"
+	labels := make([]string, 0, 32)
+	v6 := parsed.To16()
+	for i := len(v6) - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[v6[i]&0x0f]), string(hexDigits[v6[i]>>4]))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa", nil
+}
+
+// IsReverseZone reports whether a zone name is an in-addr.arpa or ip6.arpa zone
+func IsReverseZone(zoneName string) bool {
+	zoneName = strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	return strings.HasSuffix(zoneName, ".in-addr.arpa") || strings.HasSuffix(zoneName, ".ip6.arpa")
+}
+
+// reverseNameInZone computes the PTR name for ip and checks it falls within the zone
+func reverseNameInZone(ctx context.Context, zoneID, ip string) (string, error) {
+	zone, err := route53.GetZone(ctx, zoneID)
+	if err != nil || zone == nil {
+		return "", fmt.Errorf("reverse zone not found")
+	}
+
+	name, err := ReverseName(ip)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(name, "."+strings.ToLower(zone.Name)) {
+		return "", fmt.Errorf("%s is not within reverse zone %s", name, zone.Name)
+	}
+
+	return name, nil
+}
+
+// updatePTR points the PTR record for ip at the record's hostname and removes
+// the PTR for the previous IP when it lived in the same reverse zone
+func updatePTR(ctx context.Context, record *database.DDNSRecord, previousIP, ip string) error {
+	if record.ReverseZoneID == "" {
+		return nil
+	}
+
+	name, err := reverseNameInZone(ctx, record.ReverseZoneID, ip)
+	if err != nil {
+		return err
+	}
+
+	target := []string{record.Hostname + "."}
+	if err := route53.UpsertRecordValues(ctx, record.ReverseZoneID, name, types.RRTypePtr, target, EffectiveTTL(record)); err != nil {
+		return err
+	}
+
+	if previousIP != "" && previousIP != ip {
+		deletePTR(ctx, record, previousIP)
+	}
+
+	return nil
+}
+
+// deletePTR removes the PTR record for ip, ignoring IPs outside the reverse zone
+func deletePTR(ctx context.Context, record *database.DDNSRecord, ip string) {
+	if record.ReverseZoneID == "" || ip == "" {
+		return
+	}
+
+	name, err := reverseNameInZone(ctx, record.ReverseZoneID, ip)
+	if err != nil {
+		return
+	}
+
+	target := []string{record.Hostname + "."}
+	if err := route53.DeleteRecordValues(ctx, record.ReverseZoneID, name, types.RRTypePtr, target, EffectiveTTL(record)); err != nil {
+		fmt.Printf("Warning: Failed to delete PTR record: %v\n", err)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"dynamic-route-53-dns/internal/tracing"
+)
+
+// tokenCacheTTL is how long a successful token verification is remembered
+const tokenCacheTTL = 60 * time.Second
+
+// tokenCacheKey identifies a verified hostname/token pair. Only a digest of
+// the presented token is kept, never the plaintext.
+type tokenCacheKey struct {
+	hostname    string
+	tokenDigest string
+}
+
+// tokenCacheEntry records the stored hash a token was verified against
+type tokenCacheEntry struct {
+	tokenHash string
+	expiresAt time.Time
+}
+
+// Cache for successful bcrypt verifications
+type tokenCache struct {
+	entries map[tokenCacheKey]tokenCacheEntry
+	mu      sync.RWMutex
+}
+
+var verifiedTokens = &tokenCache{
+	entries: make(map[tokenCacheKey]tokenCacheEntry),
+}
+
+// digestToken returns a SHA-256 digest of a token for use as a cache key
+func digestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyTokenCached verifies a token against its hash, skipping bcrypt when the
+// same token was recently verified against the same stored hash
+func verifyTokenCached(ctx context.Context, hostname, token, hash string) bool {
+	key := tokenCacheKey{hostname: hostname, tokenDigest: digestToken(token)}
+
+	verifiedTokens.mu.RLock()
+	entry, ok := verifiedTokens.entries[key]
+	verifiedTokens.mu.RUnlock()
+	if ok && entry.tokenHash == hash && time.Now().Before(entry.expiresAt) {
+		return true
+	}
+
+	var valid bool
+	tracing.Capture(ctx, "bcrypt.verify", func() error {
+		valid = VerifyToken(token, hash)
+		return nil
+	})
+	if !valid {
+		return false
+	}
+
+	verifiedTokens.mu.Lock()
+	defer verifiedTokens.mu.Unlock()
+	now := time.Now()
+	for k, e := range verifiedTokens.entries {
+		if now.After(e.expiresAt) {
+			delete(verifiedTokens.entries, k)
+		}
+	}
+	verifiedTokens.entries[key] = tokenCacheEntry{
+		tokenHash: hash,
+		expiresAt: now.Add(tokenCacheTTL),
+	}
+	return true
+}
+
+// InvalidateTokenCache forgets all cached verifications for a hostname
+func InvalidateTokenCache(hostname string) {
+	verifiedTokens.mu.Lock()
+	defer verifiedTokens.mu.Unlock()
+	for k := range verifiedTokens.entries {
+		if k.hostname == hostname {
+			delete(verifiedTokens.entries, k)
+		}
+	}
+}
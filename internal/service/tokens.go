@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"dynamic-route-53-dns/internal/auth"
+	"dynamic-route-53-dns/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// sharedTokenPrefix marks a shared update token. Shared tokens have the form
+// "mt.<id>.<secret>"; per-record tokens are base64url and never contain a dot.
+const sharedTokenPrefix = "mt."
+
+// DefaultMinTokenLength is the shortest user-supplied update token accepted.
+// Generated tokens are 43 characters of base64url.
+const DefaultMinTokenLength = 20
+
+// minTokenDistinct is how many different characters a user-supplied token
+// must contain, rejecting repeats such as "aaaa..." or "abab..."
+const minTokenDistinct = 8
+
+// MinTokenLength returns MIN_TOKEN_LENGTH, falling back to
+// DefaultMinTokenLength when unset or shorter than the default
+func MinTokenLength() int {
+	value := os.Getenv("MIN_TOKEN_LENGTH")
+	if value == "" {
+		return DefaultMinTokenLength
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < DefaultMinTokenLength {
+		fmt.Printf("Warning: Ignoring MIN_TOKEN_LENGTH %q, must be at least %d\n", value, DefaultMinTokenLength)
+		return DefaultMinTokenLength
+	}
+	return length
+}
+
+// ValidateTokenStrength rejects user-supplied update tokens that are too
+// short or draw on too few characters to resist guessing
+func ValidateTokenStrength(token string) error {
+	if minLength := MinTokenLength(); len(token) < minLength {
+		return validationErrorf("token must be at least %d characters", minLength)
+	}
+
+	distinct := make(map[rune]bool)
+	var lower, upper, digit, other bool
+	for _, r := range token {
+		distinct[r] = true
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	if len(distinct) < minTokenDistinct {
+		return validationErrorf("token must contain at least %d different characters", minTokenDistinct)
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	if classes < 2 {
+		return validationErrorf("token must mix at least two of lowercase, uppercase, digits and symbols")
+	}
+
+	return nil
+}
+
+// validateCustomToken checks an update token the user chose for a record.
+// It must be strong enough and must not look like a shared token.
+func validateCustomToken(token string) error {
+	if strings.HasPrefix(token, sharedTokenPrefix) {
+		return validationErrorf("token must not start with %q", sharedTokenPrefix)
+	}
+	return ValidateTokenStrength(token)
+}
+
+// TokenService manages shared update tokens covering several hostnames
+type TokenService struct {
+	store database.Store
+}
+
+// NewTokenService creates a new token service
+func NewTokenService() *TokenService {
+	return &TokenService{store: database.GetStore()}
+}
+
+// CreateToken creates a shared token for the given hostnames and returns the
+// plaintext token, which is never stored and cannot be shown again
+func (s *TokenService) CreateToken(ctx context.Context, name string, hostnames []string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", validationErrorf("token name is required")
+	}
+	if len(hostnames) == 0 {
+		return "", validationErrorf("select at least one hostname")
+	}
+
+	seen := make(map[string]bool)
+	var unique []string
+	for _, hostname := range hostnames {
+		if seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+
+		record, err := s.store.GetDDNSRecord(ctx, hostname)
+		if err != nil {
+			return "", err
+		}
+		if record == nil {
+			return "", validationErrorf("hostname %s is not a DDNS record", hostname)
+		}
+		unique = append(unique, hostname)
+	}
+	sort.Strings(unique)
+
+	secret, err := auth.GenerateUpdateToken()
+	if err != nil {
+		return "", err
+	}
+	tokenHash, err := HashToken(secret)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	if err := s.store.CreateUpdateToken(ctx, &database.UpdateToken{
+		ID:        id,
+		Name:      name,
+		TokenHash: tokenHash,
+		Hostnames: unique,
+	}); err != nil {
+		return "", err
+	}
+
+	return sharedTokenPrefix + id + "." + secret, nil
+}
+
+// ListTokens lists all shared tokens
+func (s *TokenService) ListTokens(ctx context.Context) ([]database.UpdateToken, error) {
+	return s.store.ListUpdateTokens(ctx)
+}
+
+// RevokeToken deletes a shared token, revoking it for all of its hostnames
+func (s *TokenService) RevokeToken(ctx context.Context, id string) error {
+	if err := s.store.DeleteUpdateToken(ctx, id); err != nil {
+		return err
+	}
+	InvalidateTokenCache(sharedTokenCacheKey(id))
+	return nil
+}
+
+// parseSharedToken splits a shared token into its ID and secret
+func parseSharedToken(token string) (string, string, bool) {
+	if !strings.HasPrefix(token, sharedTokenPrefix) {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, sharedTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// sharedTokenCacheKey is the token cache key for a shared token's verifications
+func sharedTokenCacheKey(id string) string {
+	return "token:" + id
+}
+
+// verifySharedToken reports whether token is a shared token allowed to update hostname
+func verifySharedToken(ctx context.Context, store database.Store, hostname, token string) bool {
+	id, secret, ok := parseSharedToken(token)
+	if !ok {
+		return false
+	}
+
+	shared, err := store.GetUpdateToken(ctx, id)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load shared token: %v\n", err)
+		return false
+	}
+	if shared == nil || !shared.CanUpdate(hostname) {
+		return false
+	}
+
+	return verifyTokenCached(ctx, sharedTokenCacheKey(id), secret, shared.TokenHash)
+}
+
+// verifyUpdateToken checks token against the record's own token or, when it
+// has the shared token prefix, against the shared token it names. Only one is
+// tried, so a shared token never pays for a bcrypt compare against the
+// record's hash. recordToken reports whether it was the record's own token.
+func verifyUpdateToken(ctx context.Context, store database.Store, hostname, token, recordHash string) (recordToken, ok bool) {
+	if strings.HasPrefix(token, sharedTokenPrefix) {
+		return false, verifySharedToken(ctx, store, hostname, token)
+	}
+	ok = verifyTokenCached(ctx, hostname, token, recordHash)
+	return ok, ok
+}
+
+// removeHostnameFromTokens drops a deleted hostname from every shared token so
+// a record later recreated under the same name isn't silently covered
+func removeHostnameFromTokens(ctx context.Context, store database.Store, hostname string) {
+	tokens, err := store.ListUpdateTokens(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Failed to list shared tokens: %v\n", err)
+		return
+	}
+
+	for _, token := range tokens {
+		if !token.CanUpdate(hostname) {
+			continue
+		}
+		var remaining []string
+		for _, h := range token.Hostnames {
+			if h != hostname {
+				remaining = append(remaining, h)
+			}
+		}
+		if err := store.UpdateTokenHostnames(ctx, token.ID, remaining); err != nil {
+			fmt.Printf("Warning: Failed to update shared token %s: %v\n", token.ID, err)
+		}
+	}
+}
@@ -2,148 +2,1223 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/geoip"
+	"dynamic-route-53-dns/internal/metrics"
 	"dynamic-route-53-dns/internal/route53"
 )
 
 // UpdateService handles DDNS update requests
-type UpdateService struct{}
+type UpdateService struct {
+	store database.Store
+}
 
 // NewUpdateService creates a new update service
 func NewUpdateService() *UpdateService {
-	return &UpdateService{}
+	return &UpdateService{store: database.GetStore()}
 }
 
 // UpdateResult represents the result of a DDNS update
 type UpdateResult struct {
-	Success  bool
-	Code     string // DynDNS2 response code
-	Message  string
-	IP       string
+	Success bool
+	Code    string // DynDNS2 response code
+	Message string
+	IP      string
+	TTL     int64       // TTL of the published record, set when DNS was changed
+	Plan    *UpdatePlan // set for dry-run requests
+
+	// Effective rate limit for the hostname, zero if not yet evaluated
+	RateLimit     int
+	RateRemaining int
+}
+
+// UpdatePlan describes the change an update would make
+type UpdatePlan struct {
+	Hostname   string `json:"hostname"`
+	RecordType string `json:"record_type"`
+	PreviousIP string `json:"previous_ip"`
+	NewIP      string `json:"new_ip"`
+	IPChanged  bool   `json:"ip_changed"`
+	Wildcard   bool   `json:"wildcard"`
 }
 
 // Response codes for DynDNS2 protocol
 const (
-	ResponseGood    = "good"
-	ResponseNoChg   = "nochg"
-	ResponseNoHost  = "nohost"
-	ResponseBadAuth = "badauth"
-	ResponseAbuse   = "abuse"
-	ResponseBadIP   = "911"
+	ResponseGood      = "good"
+	ResponseNoChg     = "nochg"
+	ResponseNoHost    = "nohost"   // hostname does not exist
+	ResponseBadAuth   = "badauth"  // token did not match
+	ResponseNotFQDN   = "notfqdn"  // hostname is not a fully-qualified domain name
+	ResponseBadAgent  = "badagent" // client refused by the User-Agent policy; clients stop updating
+	ResponseAbuse     = "abuse"    // hostname blocked or rate limited
+	ResponseDNSErr    = "dnserr"   // Route 53 rejected or failed the change
+	ResponseServerErr = "911"      // internal failure, client should retry later
+
+	// ResponseBadIP refuses an invalid or unusable address or value. DynDNS2
+	// has no code for bad input; 911 has clients retry later, where badagent
+	// would make them stop for good.
+	ResponseBadIP = "911"
 )
 
+// DefaultUpdateRateLimit is the hourly update ceiling for records without their own limit
+const DefaultUpdateRateLimit = 60
+
+// NoChgRateMultiplier scales the update ceiling to give nochg pings more headroom
+const NoChgRateMultiplier = 4
+
+// UpdateRateLimit returns the effective hourly update ceiling for a record
+func UpdateRateLimit(record *database.DDNSRecord) int {
+	if record != nil && record.RateLimitPerHour > 0 {
+		return record.RateLimitPerHour
+	}
+	return DefaultUpdateRateLimit
+}
+
+// NoChgRateLimit returns the hourly ceiling for requests that leave DNS unchanged
+func NoChgRateLimit(record *database.DDNSRecord) int {
+	return UpdateRateLimit(record) * NoChgRateMultiplier
+}
+
+// updateRateLimitKey is the rate limit key counting a hostname's IP changes
+func updateRateLimitKey(hostname string) string {
+	return fmt.Sprintf("ddns:%s", hostname)
+}
+
+// noChgRateLimitKey is the rate limit key counting a hostname's nochg pings
+func noChgRateLimitKey(hostname string) string {
+	return fmt.Sprintf("ddns:nochg:%s", hostname)
+}
+
+// UpdateLockoutThreshold is how many failed token checks lock a hostname's
+// update endpoint, independent of the hourly rate limit
+const UpdateLockoutThreshold = 10
+
+// UpdateLockoutWindow is how long failures are counted and a lockout lasts,
+// measured from the first failure
+const UpdateLockoutWindow = 15 * time.Minute
+
+// updateLockoutKey is the rate limit key counting a hostname's failed token checks
+func updateLockoutKey(hostname string) string {
+	return fmt.Sprintf("ddns:badauth:%s", hostname)
+}
+
+// FlapThreshold is how many changes back to the address just replaced lock
+// a hostname, catching two clients fighting over one record
+const FlapThreshold = 6
+
+// FlapWindow is how long flips are counted and the lock lasts, measured from
+// the first flip
+const FlapWindow = 30 * time.Minute
+
+// flapKey is the rate limit key counting a hostname's IP flips
+func flapKey(hostname string) string {
+	return fmt.Sprintf("ddns:flap:%s", hostname)
+}
+
 // ValidateIP validates an IP address (IPv4 or IPv6)
 func ValidateIP(ip string) bool {
 	return net.ParseIP(ip) != nil
 }
 
-// ProcessUpdate processes a DDNS update request
-func (s *UpdateService) ProcessUpdate(ctx context.Context, hostname, token, ip, sourceIP, userAgent string) *UpdateResult {
-	// Validate IP format
-	if !ValidateIP(ip) {
+// cgnatRange is the shared address space used by carrier-grade NAT (RFC 6598)
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// IsCGNAT reports whether ip is in the carrier-grade NAT range 100.64.0.0/10
+func IsCGNAT(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && cgnatRange.Contains(parsed)
+}
+
+// ReadOnly reports whether READ_ONLY maintenance mode is enabled, in which
+// mutating operations are refused
+func ReadOnly() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("READ_ONLY"))
+	return enabled
+}
+
+// RejectCGNAT reports whether REJECT_CGNAT is enabled, refusing updates whose
+// only known address is a CGNAT source IP that is not publicly reachable
+func RejectCGNAT() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("REJECT_CGNAT"))
+	return enabled
+}
+
+// RequireExplicitMyIP reports whether DDNS_REQUIRE_EXPLICIT_MYIP is enabled,
+// refusing updates that omit myip instead of publishing the source IP
+func RequireExplicitMyIP() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DDNS_REQUIRE_EXPLICIT_MYIP"))
+	return enabled
+}
+
+// AllowInternalSourceIP reports whether DDNS_ALLOW_INTERNAL_SOURCE_IP is
+// enabled, accepting a private or loopback source IP when myip is omitted,
+// e.g. for clients inside a VPC updating a private zone
+func AllowInternalSourceIP() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DDNS_ALLOW_INTERNAL_SOURCE_IP"))
+	return enabled
+}
+
+// IsInternalIP reports whether ip is a private, loopback, link-local or
+// unspecified address that can't be a client's public IP
+func IsInternalIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && (parsed.IsPrivate() || parsed.IsLoopback() || parsed.IsLinkLocalUnicast() || parsed.IsUnspecified())
+}
+
+// Address families a record can be pinned to with AllowedFamilies
+const (
+	FamilyV4 = "v4"
+	FamilyV6 = "v6"
+)
+
+// ParseAllowedFamilies validates an AllowedFamilies setting: "v4", "v6", or
+// "both" (or empty) to accept either family
+func ParseAllowedFamilies(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", "both":
+		return "", nil
+	case FamilyV4, FamilyV6:
+		return value, nil
+	}
+	return "", validationErrorf("allowed families must be v4, v6 or both")
+}
+
+// FamilyAllowed reports whether a record accepts updates to ip's address family
+func FamilyAllowed(record *database.DDNSRecord, ip string) bool {
+	switch record.AllowedFamilies {
+	case FamilyV4:
+		return route53.RecordTypeForIP(ip) == "A"
+	case FamilyV6:
+		return route53.RecordTypeForIP(ip) == "AAAA"
+	}
+	return true
+}
+
+// DefaultUpdateTimeout bounds the database and Route 53 calls behind one update
+const DefaultUpdateTimeout = 5 * time.Second
+
+// UpdateTimeout returns UPDATE_TIMEOUT, how long an update may spend on
+// downstream calls before failing with 911 or dnserr
+func UpdateTimeout() time.Duration {
+	value := os.Getenv("UPDATE_TIMEOUT")
+	if value == "" {
+		return DefaultUpdateTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Warning: Ignoring invalid UPDATE_TIMEOUT %q\n", value)
+		return DefaultUpdateTimeout
+	}
+	return timeout
+}
+
+// RejectPrivateZones reports whether REJECT_PRIVATE_ZONES is enabled, refusing
+// DDNS records in private hosted zones even with confirmation
+func RejectPrivateZones() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("REJECT_PRIVATE_ZONES"))
+	return enabled
+}
+
+// UserAgentAllowed applies the optional DynDNS2 badagent policy. With
+// UPDATE_REQUIRE_USER_AGENT set a blank User-Agent is refused, and any agent
+// containing an entry of the comma-separated UPDATE_USER_AGENT_BLOCKLIST
+// (case-insensitive) is refused. Both are off by default.
+func UserAgentAllowed(userAgent string) bool {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		required, _ := strconv.ParseBool(os.Getenv("UPDATE_REQUIRE_USER_AGENT"))
+		return !required
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, blocked := range strings.Split(os.Getenv("UPDATE_USER_AGENT_BLOCKLIST"), ",") {
+		if blocked = strings.ToLower(strings.TrimSpace(blocked)); blocked != "" && strings.Contains(lower, blocked) {
+			return false
+		}
+	}
+	return true
+}
+
+// CountryAllowed applies the optional geo policy to a source country. With
+// ALLOWED_COUNTRIES set only the listed ISO codes may update, and a failed or
+// empty lookup is refused. Codes in BLOCKED_COUNTRIES are refused, but a failed
+// lookup is let through. Both are comma-separated and off by default.
+func CountryAllowed(country string, lookupErr error) bool {
+	country = strings.ToUpper(country)
+	known := lookupErr == nil && country != ""
+
+	if allowed := countryList("ALLOWED_COUNTRIES"); len(allowed) > 0 {
+		if !known || !allowed[country] {
+			return false
+		}
+	}
+
+	if known && countryList("BLOCKED_COUNTRIES")[country] {
+		return false
+	}
+	return true
+}
+
+// countryList parses a comma-separated list of ISO country codes from env
+func countryList(name string) map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Split(os.Getenv(name), ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			codes[code] = true
+		}
+	}
+	return codes
+}
+
+// LogIPChangesOnly reports whether LOG_IP_CHANGES_ONLY is enabled, in which
+// successful updates are only logged when the IP address actually changed
+func LogIPChangesOnly() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("LOG_IP_CHANGES_ONLY"))
+	return enabled
+}
+
+// MinChangeInterval returns DDNS_MIN_CHANGE_INTERVAL, the cooldown between
+// Route 53 changes for one hostname. Zero disables coalescing.
+func MinChangeInterval() time.Duration {
+	interval, _ := time.ParseDuration(os.Getenv("DDNS_MIN_CHANGE_INTERVAL"))
+	if interval < 0 {
+		return 0
+	}
+	return interval
+}
+
+// minChangeInterval returns the cooldown between Route 53 changes for a
+// record: its own MinUpdateInterval when set, otherwise MinChangeInterval()
+func minChangeInterval(record *database.DDNSRecord) time.Duration {
+	if record.MinUpdateInterval > 0 {
+		return time.Duration(record.MinUpdateInterval) * time.Second
+	}
+	return MinChangeInterval()
+}
+
+// UpdateRequest represents a DynDNS2 update request
+type UpdateRequest struct {
+	Hostname     string
+	Token        string
+	IP           string
+	SourceIP     string
+	UserAgent    string
+	RequestID    string
+	IPFromSource bool    // IP was taken from the connection because myip was absent
+	RecordType   string  // "A" or "AAAA" from the type hint, empty to infer from the IP, or a value type
+	Value        string  // CNAME, TXT or MX value when RecordType is a value type
+	Wildcard     *bool   // nil leaves the record's wildcard setting unchanged
+	MX           *string // "priority host" from ParseMX, nil leaves the MX record unchanged
+	BackMX       *bool   // nil leaves the backup MX setting unchanged
+	DryRun       bool    // compute the result without touching Route 53 or the database
+	Offline      bool    // DynDNS2 offline=YES: remove the published address instead
+}
+
+// WildcardName returns the wildcard record name maintained alongside hostname
+func WildcardName(hostname string) string {
+	return "*." + hostname
+}
+
+// ProcessUpdate processes a DDNS update request, counting its response code
+func (s *UpdateService) ProcessUpdate(ctx context.Context, req *UpdateRequest) *UpdateResult {
+	result := s.processUpdate(ctx, req)
+	metrics.Inc("ddns_updates_total", "code", result.Code)
+	return result
+}
+
+// processUpdate does the work of ProcessUpdate
+func (s *UpdateService) processUpdate(ctx context.Context, req *UpdateRequest) *UpdateResult {
+	hostname := req.Hostname
+	ip := req.IP
+
+	// Attribute the Route 53 change batch for auditing in the console
+	ctx = route53.WithChangeSource(ctx, fmt.Sprintf("%s req %s", hostname, req.RequestID))
+
+	// CNAME, TXT and MX updates carry their own value instead of an address
+	if IsValueType(req.RecordType) {
+		return s.processValueUpdate(ctx, req)
+	}
+	if req.Offline {
+		return s.processOffline(ctx, req)
+	}
+
+	// Behind proxies the source IP may not be the client's, so it can be
+	// required that clients always name the address
+	if req.IPFromSource && RequireExplicitMyIP() {
 		return &UpdateResult{
 			Success: false,
 			Code:    ResponseBadIP,
-			Message: "Invalid IP address format",
+			Message: "myip is required",
 		}
 	}
 
-	// Get the DDNS record
-	record, err := database.GetDDNSRecord(ctx, hostname)
-	if err != nil || record == nil {
+	// Validate the address against the requested record type, or the type
+	// its family implies when none was given
+	recordType := req.RecordType
+	if recordType == "" {
+		recordType = string(route53.RecordTypeForIP(ip))
+	}
+	normalized, err := ValidateRecordValue(recordType, ip)
+	if err != nil {
+		message := err.Error()
+		if req.IPFromSource {
+			message += "; the connection address was used, send myip explicitly"
+		}
 		return &UpdateResult{
 			Success: false,
-			Code:    ResponseNoHost,
-			Message: "Hostname not found",
+			Code:    ResponseBadIP,
+			Message: message,
 		}
 	}
+	ip = normalized
 
-	// Verify the token
-	if !VerifyToken(token, record.UpdateTokenHash) {
+	// An internal source address usually belongs to a gateway or proxy that
+	// didn't forward the client's address, so it must not be published
+	if req.IPFromSource && IsInternalIP(ip) && !AllowInternalSourceIP() {
 		return &UpdateResult{
 			Success: false,
-			Code:    ResponseBadAuth,
-			Message: "Invalid credentials",
+			Code:    ResponseBadIP,
+			Message: fmt.Sprintf("Source IP %s is not a public address, likely a proxy or gateway; pass myip with the public address", ip),
 		}
 	}
 
-	// Check if record is enabled
-	if !record.Enabled {
+	record, refused := s.admitUpdate(ctx, req, ip)
+	if refused != nil {
+		return refused
+	}
+
+	// A record pinned to one family keeps its record type; the other
+	// family is refused rather than published alongside it
+	if !FamilyAllowed(record, ip) {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				PreviousIP: record.CurrentIP,
+				NewIP:      ip,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "family_not_allowed",
+			})
+		}
+		message := fmt.Sprintf("%s updates are not allowed for this hostname", route53.RecordTypeForIP(ip))
+		if req.IPFromSource {
+			message += "; send myip with an address of the allowed family"
+		}
 		return &UpdateResult{
 			Success: false,
-			Code:    ResponseNoHost,
-			Message: "DDNS record is disabled",
+			Code:    ResponseBadIP,
+			Message: message,
 		}
 	}
 
-	// Check rate limit (60 requests per hour)
-	count, exceeded, err := database.IncrementRateLimit(ctx, fmt.Sprintf("ddns:%s", hostname), 60, 3600)
+	// Check if IP or wildcard setting has changed
+	previousIP := record.CurrentIP
+	wildcard := record.Wildcard
+	if req.Wildcard != nil {
+		wildcard = *req.Wildcard
+	}
+	mx, backMX := record.MX, record.BackMX
+	if req.MX != nil {
+		mx = *req.MX
+	}
+	if req.BackMX != nil {
+		backMX = *req.BackMX
+	}
+	mxChanged := mx != record.MX || backMX != record.BackMX
+	changed := previousIP != ip || wildcard != record.Wildcard || mxChanged
+
+	// Check rate limit. Real changes count against the record's ceiling
+	// (60 per hour by default); nochg pings use a separate, higher one.
+	key := updateRateLimitKey(hostname)
+	limit := UpdateRateLimit(record)
+	if !changed {
+		key = noChgRateLimitKey(hostname)
+		limit = NoChgRateLimit(record)
+	}
+	count, exceeded, err := s.checkRateLimit(ctx, key, limit, req.DryRun)
 	if err != nil {
 		return &UpdateResult{
 			Success: false,
-			Code:    ResponseBadIP,
+			Code:    ResponseServerErr,
 			Message: "Internal error",
 		}
 	}
 	if exceeded {
+		if !req.DryRun {
+			writeRefusedUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				PreviousIP: previousIP,
+				NewIP:      ip,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     ResponseAbuse,
+			})
+		}
 		return &UpdateResult{
-			Success: false,
-			Code:    ResponseAbuse,
-			Message: fmt.Sprintf("Rate limit exceeded: %d requests in the last hour", count),
+			Success:   false,
+			Code:      ResponseAbuse,
+			Message:   fmt.Sprintf("Rate limit exceeded: %d requests in the last hour", count),
+			RateLimit: limit,
 		}
 	}
+	remaining := limit - count
 
-	// Check if IP has changed
-	previousIP := record.CurrentIP
-	if previousIP == ip {
+	if req.DryRun {
+		result := &UpdateResult{
+			Success:       true,
+			Code:          ResponseGood,
+			Message:       "Dry run: update would be applied",
+			IP:            ip,
+			RateLimit:     limit,
+			RateRemaining: remaining,
+			Plan: &UpdatePlan{
+				Hostname:   hostname,
+				RecordType: string(route53.RecordTypeForIP(ip)),
+				PreviousIP: previousIP,
+				NewIP:      ip,
+				IPChanged:  previousIP != ip,
+				Wildcard:   wildcard,
+			},
+		}
+		if !changed {
+			result.Code = ResponseNoChg
+			result.Message = "Dry run: IP unchanged"
+		}
+		return result
+	}
+
+	// Coalesce a flapping connection: inside the cooldown after the last DNS
+	// change a new address is only stored as pending. The client is told
+	// nochg with the address DNS still serves; the next update after the
+	// cooldown, or PublishPending if none comes, publishes the latest one.
+	if previousIP != "" && previousIP != ip && wildcard == record.Wildcard && !mxChanged {
+		if interval := minChangeInterval(record); interval > 0 && time.Since(record.IPChangedAt) < interval {
+			record.PendingIP = ip
+			if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+				return &UpdateResult{
+					Success: false,
+					Code:    ResponseServerErr,
+					Message: "Internal error",
+				}
+			}
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				PreviousIP: previousIP,
+				NewIP:      ip,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Wildcard:   wildcard,
+				Status:     "deferred",
+			})
+			return &UpdateResult{
+				Success:       true,
+				Code:          ResponseNoChg,
+				Message:       "DNS change deferred until " + record.IPChangedAt.Add(interval).Format(time.RFC3339),
+				IP:            previousIP,
+				RateLimit:     limit,
+				RateRemaining: remaining,
+			}
+		}
+	}
+
+	if !changed {
+		// The connection flapped back before a deferred change was published
+		if record.PendingIP != "" {
+			record.PendingIP = ""
+			if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+				fmt.Printf("Warning: Failed to clear pending IP: %v\n", err)
+			}
+		}
 		return &UpdateResult{
-			Success: true,
-			Code:    ResponseNoChg,
-			Message: "IP unchanged",
-			IP:      ip,
+			Success:       true,
+			Code:          ResponseNoChg,
+			Message:       "IP unchanged",
+			IP:            ip,
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	// Changing straight back to the address just replaced is a flip
+	if previousIP != ip && previousIP != "" && ip == record.PreviousIP && recordFlip(ctx, s.store, hostname, req.SourceIP) {
+		writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+			PreviousIP: previousIP,
+			NewIP:      ip,
+			SourceIP:   req.SourceIP,
+			UserAgent:  req.UserAgent,
+			Wildcard:   wildcard,
+			Status:     "flapping",
+		})
+		return &UpdateResult{
+			Success:       false,
+			Code:          ResponseAbuse,
+			Message:       "IP address is alternating between clients; updates are locked",
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	// An address replaces a CNAME set by an earlier value update
+	if err := removeCNAME(ctx, record); err != nil {
+		return &UpdateResult{
+			Success:       false,
+			Code:          dnsErrorCode(err),
+			Message:       "Failed to delete CNAME record",
+			RateLimit:     limit,
+			RateRemaining: remaining,
 		}
 	}
 
 	// Update Route 53 record
-	if err := route53.UpdateRecord(ctx, record.ZoneID, hostname, ip, record.TTL); err != nil {
+	if err := publishRecord(ctx, record, hostname, ip); err != nil {
 		return &UpdateResult{
-			Success: false,
-			Code:    ResponseBadIP,
-			Message: "Failed to update DNS record",
+			Success:       false,
+			Code:          dnsErrorCode(err),
+			Message:       "Failed to update DNS record",
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	// Keep the wildcard record in step with the exact record
+	if wildcard {
+		if err := publishRecord(ctx, record, WildcardName(hostname), ip); err != nil {
+			return &UpdateResult{
+				Success:       false,
+				Code:          dnsErrorCode(err),
+				Message:       "Failed to update wildcard DNS record",
+				RateLimit:     limit,
+				RateRemaining: remaining,
+			}
+		}
+	} else if record.Wildcard && previousIP != "" {
+		if err := unpublishRecord(ctx, record, WildcardName(hostname), previousIP); err != nil {
+			fmt.Printf("Warning: Failed to delete wildcard record: %v\n", err)
+		}
+	}
+
+	// Publish the mail exchanger requested with mx and backmx
+	if mxChanged {
+		record.MX, record.BackMX = mx, backMX
+		if mx != "" {
+			if err := publishMX(ctx, record); err != nil {
+				return &UpdateResult{
+					Success:       false,
+					Code:          dnsErrorCode(err),
+					Message:       "Failed to update MX record",
+					RateLimit:     limit,
+					RateRemaining: remaining,
+				}
+			}
+		}
+	}
+
+	// Keep reverse DNS pointing at the hostname
+	if previousIP != ip {
+		if err := updatePTR(ctx, record, previousIP, ip); err != nil {
+			fmt.Printf("Warning: Failed to update PTR record: %v\n", err)
 		}
 	}
 
 	// Update database record
 	record.CurrentIP = ip
-	if err := database.UpdateDDNSRecord(ctx, record); err != nil {
+	record.PendingIP = ""
+	record.Wildcard = wildcard
+	if previousIP != ip {
+		record.PreviousIP = previousIP
+		record.IPChangedAt = time.Now().UTC()
+	}
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
 		// Log error but don't fail - Route 53 was already updated
 		fmt.Printf("Warning: Failed to update database record: %v\n", err)
 	}
 
-	// Log the update
-	log := &database.UpdateLog{
+	// Log the update. Wildcard-only changes can be left out of the history
+	// so it shows address changes alone; nochg pings are never logged.
+	if previousIP != ip || !LogIPChangesOnly() {
+		writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+			PreviousIP: previousIP,
+			NewIP:      ip,
+			SourceIP:   req.SourceIP,
+			UserAgent:  req.UserAgent,
+			Wildcard:   wildcard,
+			Status:     "success",
+		})
+	}
+	if previousIP != ip {
+		notifyIPChange(ctx, record, previousIP, ip, req.SourceIP)
+	}
+
+	return &UpdateResult{
+		Success:       true,
+		Code:          ResponseGood,
+		Message:       "Update successful",
+		IP:            ip,
+		TTL:           EffectiveTTL(record),
+		RateLimit:     limit,
+		RateRemaining: remaining,
+	}
+}
+
+// processOffline takes a hostname offline: the exact and wildcard address
+// records and the PTR are deleted until the next address update publishes
+// them again. A hostname with no address is already offline.
+func (s *UpdateService) processOffline(ctx context.Context, req *UpdateRequest) *UpdateResult {
+	hostname := req.Hostname
+
+	record, refused := s.admitUpdate(ctx, req, "")
+	if refused != nil {
+		return refused
+	}
+	previousIP := record.CurrentIP
+	changed := previousIP != ""
+
+	key := updateRateLimitKey(hostname)
+	limit := UpdateRateLimit(record)
+	if !changed {
+		key = noChgRateLimitKey(hostname)
+		limit = NoChgRateLimit(record)
+	}
+	count, exceeded, err := s.checkRateLimit(ctx, key, limit, req.DryRun)
+	if err != nil {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseServerErr,
+			Message: "Internal error",
+		}
+	}
+	if exceeded {
+		if !req.DryRun {
+			writeRefusedUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				PreviousIP: previousIP,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     ResponseAbuse,
+			})
+		}
+		return &UpdateResult{
+			Success:   false,
+			Code:      ResponseAbuse,
+			Message:   fmt.Sprintf("Rate limit exceeded: %d requests in the last hour", count),
+			RateLimit: limit,
+		}
+	}
+	remaining := limit - count
+
+	if !changed {
+		return &UpdateResult{
+			Success:       true,
+			Code:          ResponseNoChg,
+			Message:       "Hostname already offline",
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	if req.DryRun {
+		return &UpdateResult{
+			Success:       true,
+			Code:          ResponseGood,
+			Message:       "Dry run: hostname would be taken offline",
+			RateLimit:     limit,
+			RateRemaining: remaining,
+			Plan: &UpdatePlan{
+				Hostname:   hostname,
+				RecordType: string(route53.RecordTypeForIP(previousIP)),
+				PreviousIP: previousIP,
+				IPChanged:  true,
+				Wildcard:   record.Wildcard,
+			},
+		}
+	}
+
+	if err := removeAddress(ctx, record); err != nil {
+		return &UpdateResult{
+			Success:       false,
+			Code:          dnsErrorCode(err),
+			Message:       "Failed to delete DNS record",
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+		// Log error but don't fail - Route 53 was already updated
+		fmt.Printf("Warning: Failed to update database record: %v\n", err)
+	}
+
+	writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+		PreviousIP: previousIP,
+		SourceIP:   req.SourceIP,
+		UserAgent:  req.UserAgent,
+		Wildcard:   record.Wildcard,
+		Status:     "offline",
+	})
+	notifyIPChange(ctx, record, previousIP, "", req.SourceIP)
+
+	return &UpdateResult{
+		Success:       true,
+		Code:          ResponseGood,
+		Message:       "Hostname taken offline",
+		RateLimit:     limit,
+		RateRemaining: remaining,
+	}
+}
+
+// admitUpdate loads the record for an update and applies the checks every
+// update must pass: lockout, token, geo policy, enabled, pause and flapping.
+// value is the address or record value requested, for the update log. A
+// non-nil result means the update was refused.
+func (s *UpdateService) admitUpdate(ctx context.Context, req *UpdateRequest, value string) (*database.DDNSRecord, *UpdateResult) {
+	hostname := req.Hostname
+
+	// Value updates are marked in the log so they aren't read as addresses
+	logType := ""
+	if IsValueType(req.RecordType) {
+		logType = req.RecordType
+	}
+
+	// Get the DDNS record
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseServerErr,
+			Message: "Internal error",
+		}
+	}
+	if record == nil {
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseNoHost,
+			Message: "Hostname not found",
+		}
+	}
+
+	// Refuse token guessing once a hostname has collected too many failures
+	failures, locked := updateLockedOut(ctx, s.store, hostname)
+	if locked {
+		if !req.DryRun {
+			writeRefusedUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "locked_out",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseAbuse,
+			Message: "Too many failed authentication attempts",
+		}
+	}
+
+	// Verify the token: the record's own token or a shared token covering it
+	recordToken, ok := verifyUpdateToken(ctx, s.store, hostname, req.Token, record.UpdateTokenHash)
+	if !ok {
+		if !req.DryRun {
+			writeRefusedUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     ResponseBadAuth,
+			})
+			alertBadAuth(ctx, s.store, hostname, req.SourceIP)
+			recordUpdateAuthFailure(ctx, s.store, hostname, req.SourceIP)
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseBadAuth,
+			Message: "Invalid credentials",
+		}
+	}
+
+	if failures > 0 && !req.DryRun {
+		clearUpdateAuthFailures(ctx, s.store, hostname)
+	}
+
+	// Refuse updates from countries outside the geo policy. This follows the
+	// token check so unauthenticated requests never reach the update log.
+	country, geoErr := geoip.Country(req.SourceIP)
+	if !CountryAllowed(country, geoErr) {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				Country:    country,
+				UserAgent:  req.UserAgent,
+				Status:     "geo_blocked",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseBadAuth,
+			Message: "Updates are not allowed from this location",
+		}
+	}
+
+	// An expired record token is rejected until it is regenerated
+	if recordToken && record.TokenExpired() {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "token_expired",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseBadAuth,
+			Message: "Update token has expired",
+		}
+	}
+
+	// Upgrade tokens hashed at an older, cheaper cost
+	if recordToken && !req.DryRun && NeedsRehash(record.UpdateTokenHash) {
+		if tokenHash, err := HashToken(req.Token); err == nil {
+			if err := s.store.UpdateTokenHash(ctx, hostname, tokenHash); err != nil {
+				fmt.Printf("Warning: Failed to rehash update token: %v\n", err)
+			} else {
+				record.UpdateTokenHash = tokenHash
+			}
+		}
+	}
+
+	// A disabled record answers nochg rather than nohost or abuse, which make
+	// many clients give up for good; they resume once it is re-enabled
+	if !record.Enabled {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				PreviousIP: record.CurrentIP,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "disabled",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: true,
+			Code:    ResponseNoChg,
+			Message: "DDNS record is disabled",
+			IP:      record.CurrentIP,
+		}
+	}
+
+	// A CGNAT source address is not the client's public IP
+	if req.IPFromSource && IsCGNAT(value) && RejectCGNAT() {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				PreviousIP: record.CurrentIP,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "cgnat",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseBadIP,
+			Message: "Source IP is behind carrier-grade NAT; pass myip with the public address",
+		}
+	}
+
+	// Hold updates during a maintenance pause; the pause expires on its own
+	if record.IsPaused() {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				PreviousIP: record.CurrentIP,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "paused",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: true,
+			Code:    ResponseNoChg,
+			Message: fmt.Sprintf("Updates paused until %s", record.PausedUntil.Format(time.RFC3339)),
+			IP:      record.CurrentIP,
+		}
+	}
+
+	// Two clients sharing a hostname and token keep swapping the address;
+	// refuse updates until the flip window runs out or an operator clears it
+	if flapping(ctx, s.store, hostname) {
+		if !req.DryRun {
+			writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: logType,
+				PreviousIP: record.CurrentIP,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     "flapping",
+			})
+		}
+		return nil, &UpdateResult{
+			Success: false,
+			Code:    ResponseAbuse,
+			Message: "IP address is alternating between clients; updates are locked",
+		}
+	}
+
+	return record, nil
+}
+
+// dnsErrorCode maps a Route 53 failure to a response code. Throttling that
+// outlasted the retries is temporary, so the client is told to retry later.
+func dnsErrorCode(err error) string {
+	if errors.Is(err, route53.ErrThrottled) {
+		return ResponseServerErr
+	}
+	return ResponseDNSErr
+}
+
+// PublishPending publishes addresses deferred by the change cooldown once it
+// has ended. Clients told nochg only update again when their address
+// changes, so this runs on a schedule rather than waiting for them. Returns
+// how many records were published; per-record failures are logged and left
+// pending for the next run.
+func (s *UpdateService) PublishPending(ctx context.Context, now time.Time) (int, error) {
+	records, err := s.store.ListDDNSRecords(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for i := range records {
+		record := &records[i]
+		if record.PendingIP == "" || !record.Enabled || record.IsPaused() {
+			continue
+		}
+		if now.Sub(record.IPChangedAt) < minChangeInterval(record) {
+			continue
+		}
+		if err := s.publishPending(ctx, record, now); err != nil {
+			fmt.Printf("Warning: Failed to publish pending IP for %s: %v\n", record.Hostname, err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+// publishPending publishes a record's pending address like an update would
+func (s *UpdateService) publishPending(ctx context.Context, record *database.DDNSRecord, now time.Time) error {
+	hostname := record.Hostname
+	previousIP, ip := record.CurrentIP, record.PendingIP
+
+	record.PendingIP = ""
+	if ip == previousIP {
+		return s.store.UpdateDDNSRecord(ctx, record)
+	}
+
+	if err := removeCNAME(ctx, record); err != nil {
+		return err
+	}
+	if err := publishRecord(ctx, record, hostname, ip); err != nil {
+		return err
+	}
+	if record.Wildcard {
+		if err := publishRecord(ctx, record, WildcardName(hostname), ip); err != nil {
+			return err
+		}
+	}
+	if err := updatePTR(ctx, record, previousIP, ip); err != nil {
+		fmt.Printf("Warning: Failed to update PTR record: %v\n", err)
+	}
+
+	record.CurrentIP = ip
+	record.PreviousIP = previousIP
+	record.IPChangedAt = now
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+		return err
+	}
+
+	writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
 		PreviousIP: previousIP,
 		NewIP:      ip,
-		SourceIP:   sourceIP,
-		UserAgent:  userAgent,
+		Wildcard:   record.Wildcard,
 		Status:     "success",
-		Timestamp:  time.Now().UTC(),
+	})
+	notifyIPChange(ctx, record, previousIP, ip, "")
+	return nil
+}
+
+// writeUpdateLog records an update log entry for hostname, stamping it with
+// the current time. Failures are logged but never fail the update.
+func writeUpdateLog(ctx context.Context, store database.Store, hostname string, entry *database.UpdateLog) {
+	entry.PK = fmt.Sprintf("LOG#%s", hostname)
+	entry.Timestamp = time.Now().UTC()
+	if entry.Country == "" && geoip.Enabled() {
+		// Logging fails open: an unknown country is simply left blank
+		entry.Country, _ = geoip.Country(entry.SourceIP)
 	}
-	// Overwrite the PK to use hostname
-	log.PK = fmt.Sprintf("LOG#%s", hostname)
-	if err := database.CreateUpdateLog(ctx, log); err != nil {
-		// Log error but don't fail
+	if err := store.CreateUpdateLog(ctx, entry); err != nil {
 		fmt.Printf("Warning: Failed to create update log: %v\n", err)
 	}
+}
+
+// writeRefusedUpdateLog logs a refused update and counts it for the
+// dashboard summary
+func writeRefusedUpdateLog(ctx context.Context, store database.Store, hostname string, entry *database.UpdateLog) {
+	status := entry.Status
+	if status != ResponseBadAuth {
+		status = ResponseAbuse
+	}
+	countRefusedUpdate(ctx, store, status)
+	writeUpdateLog(ctx, store, hostname, entry)
+}
+
+// checkRateLimit counts the request against an hourly limit.
+// Dry runs only read the current count so they never consume quota.
+func (s *UpdateService) checkRateLimit(ctx context.Context, key string, limit int, dryRun bool) (int, bool, error) {
+	if !dryRun {
+		return s.store.IncrementRateLimit(ctx, key, limit, 3600)
+	}
+
+	count, err := s.store.GetRateLimitCount(ctx, key)
+	if err != nil {
+		return 0, false, err
+	}
+	return count + 1, count+1 > limit, nil
+}
+
+// updateLockedOut returns a hostname's current count of failed token checks
+// and whether it has reached UpdateLockoutThreshold. Lookup errors fail open
+// so an outage doesn't block legitimate clients.
+func updateLockedOut(ctx context.Context, store database.Store, hostname string) (int, bool) {
+	entry, err := store.GetRateLimitEntry(ctx, updateLockoutKey(hostname))
+	if err != nil {
+		fmt.Printf("Warning: Failed to check update lockout: %v\n", err)
+		return 0, false
+	}
+	if entry == nil {
+		return 0, false
+	}
+	return entry.Count, entry.Count >= UpdateLockoutThreshold
+}
+
+// recordUpdateAuthFailure counts a failed token check towards the lockout,
+// announcing the lockout when this failure triggers it
+func recordUpdateAuthFailure(ctx context.Context, store database.Store, hostname, sourceIP string) {
+	window := int64(UpdateLockoutWindow.Seconds())
+	count, _, err := store.IncrementRateLimit(ctx, updateLockoutKey(hostname), UpdateLockoutThreshold, window)
+	if err != nil {
+		fmt.Printf("Warning: Failed to record update auth failure: %v\n", err)
+		return
+	}
+	if count == UpdateLockoutThreshold {
+		notifyUpdateLocked(ctx, hostname, "too many failed token checks", sourceIP)
+	}
+}
+
+// clearUpdateAuthFailures resets the failure count after a successful check
+func clearUpdateAuthFailures(ctx context.Context, store database.Store, hostname string) {
+	if err := store.DeleteRateLimit(ctx, updateLockoutKey(hostname)); err != nil {
+		fmt.Printf("Warning: Failed to reset update auth failures: %v\n", err)
+	}
+}
+
+// flapping reports whether a hostname is locked for alternating between
+// addresses. Lookup errors fail open like the auth lockout.
+func flapping(ctx context.Context, store database.Store, hostname string) bool {
+	entry, err := store.GetRateLimitEntry(ctx, flapKey(hostname))
+	if err != nil {
+		fmt.Printf("Warning: Failed to check IP flapping: %v\n", err)
+		return false
+	}
+	return entry != nil && entry.Count >= FlapThreshold
+}
+
+// recordFlip counts an IP flip and reports whether it reached FlapThreshold
+func recordFlip(ctx context.Context, store database.Store, hostname, sourceIP string) bool {
+	window := int64(FlapWindow.Seconds())
+	count, _, err := store.IncrementRateLimit(ctx, flapKey(hostname), FlapThreshold, window)
+	if err != nil {
+		fmt.Printf("Warning: Failed to record IP flip: %v\n", err)
+		return false
+	}
+	if count == FlapThreshold {
+		fmt.Printf("Warning: %s is flapping between addresses, locking updates\n", hostname)
+		notifyUpdateLocked(ctx, hostname, "IP address is alternating between clients", sourceIP)
+	}
+	return count >= FlapThreshold
+}
+
+// CheckToken verifies the token for a hostname without changing anything.
+// On success the result carries the currently stored IP.
+func (s *UpdateService) CheckToken(ctx context.Context, hostname, token string) *UpdateResult {
+	record, err := s.store.GetDDNSRecord(ctx, hostname)
+	if err != nil {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseServerErr,
+			Message: "Internal error",
+		}
+	}
+	if record == nil {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseNoHost,
+			Message: "Hostname not found",
+		}
+	}
+
+	failures, locked := updateLockedOut(ctx, s.store, hostname)
+	if locked {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseAbuse,
+			Message: "Too many failed authentication attempts",
+		}
+	}
+
+	recordToken, ok := verifyUpdateToken(ctx, s.store, hostname, token, record.UpdateTokenHash)
+	if !ok {
+		recordUpdateAuthFailure(ctx, s.store, hostname, "")
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseBadAuth,
+			Message: "Invalid credentials",
+		}
+	}
+	if failures > 0 {
+		clearUpdateAuthFailures(ctx, s.store, hostname)
+	}
+
+	if recordToken && record.TokenExpired() {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseBadAuth,
+			Message: "Invalid credentials",
+		}
+	}
+
+	if !record.Enabled {
+		return &UpdateResult{
+			Success: true,
+			Code:    ResponseNoChg,
+			Message: "DDNS record is disabled",
+			IP:      record.CurrentIP,
+		}
+	}
 
 	return &UpdateResult{
 		Success: true,
 		Code:    ResponseGood,
-		Message: "Update successful",
-		IP:      ip,
+		Message: "Credentials valid",
+		IP:      record.CurrentIP,
 	}
 }
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"dynamic-route-53-dns/internal/database"
+	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// maxTXTLength bounds TXT data set through the update endpoint
+const maxTXTLength = 1024
+
+// IsValueType reports whether an update type carries an explicit value
+// (CNAME, TXT or MX) rather than an address
+func IsValueType(recordType string) bool {
+	switch recordType {
+	case "CNAME", "TXT", "MX":
+		return true
+	}
+	return false
+}
+
+// ValidateRecordValue checks an update value against its declared record
+// type and returns it normalized: an IPv4 address for A, an IPv6 address for
+// AAAA, a hostname for CNAME, printable text for TXT and "[priority] host"
+// for MX
+func ValidateRecordValue(recordType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", validationErrorf("value is required for type=%s", recordType)
+	}
+
+	switch recordType {
+	case "A", "AAAA":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", validationErrorf("%q is not a valid IP address", value)
+		}
+		if ip.To4() != nil && recordType == "AAAA" {
+			return "", validationErrorf("type=AAAA requested but %s is an IPv4 address", value)
+		}
+		if ip.To4() == nil && recordType == "A" {
+			return "", validationErrorf("type=A requested but %s is an IPv6 address", value)
+		}
+		return ip.String(), nil
+	case "CNAME":
+		target := strings.ToLower(strings.TrimSuffix(value, "."))
+		if !ValidateFQDN(target) {
+			return "", validationErrorf("CNAME target %q is not a valid hostname", value)
+		}
+		return target, nil
+	case "TXT":
+		if len(value) > maxTXTLength {
+			return "", validationErrorf("TXT value must be at most %d characters", maxTXTLength)
+		}
+		for _, r := range value {
+			if r < 0x20 || r > 0x7e {
+				return "", validationErrorf("TXT value must be printable ASCII")
+			}
+		}
+		return value, nil
+	case "MX":
+		return ParseMX(value)
+	default:
+		return "", validationErrorf("unsupported record type %q", recordType)
+	}
+}
+
+// recordValue returns the value a record currently publishes for a value type
+func recordValue(record *database.DDNSRecord, recordType string) string {
+	switch recordType {
+	case "CNAME":
+		return record.CNAME
+	case "TXT":
+		return record.TXT
+	case "MX":
+		return record.MX
+	}
+	return ""
+}
+
+// valueConflict explains why a record can't take a value of recordType, as
+// a CNAME may not share its name with any other record
+func valueConflict(record *database.DDNSRecord, recordType string) string {
+	if recordType == "CNAME" && (record.MX != "" || record.TXT != "") {
+		return "a CNAME cannot be published alongside the hostname's MX or TXT record"
+	}
+	if recordType != "CNAME" && record.CNAME != "" {
+		return fmt.Sprintf("a %s record cannot be published alongside the hostname's CNAME", recordType)
+	}
+	return ""
+}
+
+// publishCNAME upserts the hostname's CNAME, and the wildcard's when enabled
+func publishCNAME(ctx context.Context, record *database.DDNSRecord) error {
+	if err := route53.UpsertRecord(ctx, record.ZoneID, record.Hostname, types.RRTypeCname, record.CNAME, EffectiveTTL(record)); err != nil {
+		return err
+	}
+	if record.Wildcard {
+		return route53.UpsertRecord(ctx, record.ZoneID, WildcardName(record.Hostname), types.RRTypeCname, record.CNAME, EffectiveTTL(record))
+	}
+	return nil
+}
+
+// removeCNAME deletes a CNAME published by a value update so an address
+// record can take its place
+func removeCNAME(ctx context.Context, record *database.DDNSRecord) error {
+	if record.CNAME == "" {
+		return nil
+	}
+	if err := unpublishLive(ctx, record, record.Hostname, types.RRTypeCname); err != nil {
+		return err
+	}
+	if record.Wildcard {
+		if err := unpublishLive(ctx, record, WildcardName(record.Hostname), types.RRTypeCname); err != nil {
+			return err
+		}
+	}
+	record.CNAME = ""
+	return nil
+}
+
+// removeAddress deletes the hostname's address records and PTR so a CNAME
+// can take their place
+func removeAddress(ctx context.Context, record *database.DDNSRecord) error {
+	if record.CurrentIP == "" {
+		return nil
+	}
+	if err := unpublishIfPresent(ctx, record, record.Hostname); err != nil {
+		return err
+	}
+	if record.Wildcard {
+		if err := unpublishIfPresent(ctx, record, WildcardName(record.Hostname)); err != nil {
+			return err
+		}
+	}
+	deletePTR(ctx, record, record.CurrentIP)
+
+	record.PreviousIP = record.CurrentIP
+	record.CurrentIP = ""
+	record.PendingIP = ""
+	record.IPChangedAt = time.Now().UTC()
+	return nil
+}
+
+// unpublishLive deletes the record set of recordType at name, reading it
+// back first so the delete matches whatever values and TTL are live. A
+// record set that is already gone is not an error.
+func unpublishLive(ctx context.Context, record *database.DDNSRecord, name string, recordType types.RRType) error {
+	existing, err := route53.GetRecord(ctx, record.ZoneID, name, recordType)
+	if err != nil || existing == nil {
+		return err
+	}
+	err = route53.DeleteRecordValues(ctx, record.ZoneID, name, recordType, existing.Values, existing.TTL)
+	if route53.IsRecordNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// processValueUpdate applies an update carrying an explicit CNAME, TXT or
+// MX value. It passes the same checks and rate limits as an address update.
+func (s *UpdateService) processValueUpdate(ctx context.Context, req *UpdateRequest) *UpdateResult {
+	hostname := req.Hostname
+	recordType := req.RecordType
+
+	value, err := ValidateRecordValue(recordType, req.Value)
+	if err != nil {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseBadIP,
+			Message: err.Error(),
+		}
+	}
+
+	record, refused := s.admitUpdate(ctx, req, value)
+	if refused != nil {
+		return refused
+	}
+
+	if conflict := valueConflict(record, recordType); conflict != "" {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseBadIP,
+			Message: conflict,
+		}
+	}
+
+	previous := recordValue(record, recordType)
+	backMX := record.BackMX
+	if recordType == "MX" && req.BackMX != nil {
+		backMX = *req.BackMX
+	}
+	changed := previous != value || backMX != record.BackMX
+
+	// Value changes share the address update limits
+	key := updateRateLimitKey(hostname)
+	limit := UpdateRateLimit(record)
+	if !changed {
+		key = noChgRateLimitKey(hostname)
+		limit = NoChgRateLimit(record)
+	}
+	count, exceeded, err := s.checkRateLimit(ctx, key, limit, req.DryRun)
+	if err != nil {
+		return &UpdateResult{
+			Success: false,
+			Code:    ResponseServerErr,
+			Message: "Internal error",
+		}
+	}
+	if exceeded {
+		if !req.DryRun {
+			writeRefusedUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+				RecordType: recordType,
+				PreviousIP: previous,
+				NewIP:      value,
+				SourceIP:   req.SourceIP,
+				UserAgent:  req.UserAgent,
+				Status:     ResponseAbuse,
+			})
+		}
+		return &UpdateResult{
+			Success:   false,
+			Code:      ResponseAbuse,
+			Message:   fmt.Sprintf("Rate limit exceeded: %d requests in the last hour", count),
+			RateLimit: limit,
+		}
+	}
+	remaining := limit - count
+
+	if req.DryRun {
+		result := &UpdateResult{
+			Success:       true,
+			Code:          ResponseGood,
+			Message:       "Dry run: update would be applied",
+			IP:            value,
+			RateLimit:     limit,
+			RateRemaining: remaining,
+			Plan: &UpdatePlan{
+				Hostname:   hostname,
+				RecordType: recordType,
+				PreviousIP: previous,
+				NewIP:      value,
+				IPChanged:  previous != value,
+				Wildcard:   record.Wildcard,
+			},
+		}
+		if !changed {
+			result.Code = ResponseNoChg
+			result.Message = "Dry run: value unchanged"
+		}
+		return result
+	}
+
+	if !changed {
+		return &UpdateResult{
+			Success:       true,
+			Code:          ResponseNoChg,
+			Message:       "Value unchanged",
+			IP:            value,
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	dnsFailure := func(err error, what string) *UpdateResult {
+		return &UpdateResult{
+			Success:       false,
+			Code:          dnsErrorCode(err),
+			Message:       "Failed to update " + what,
+			RateLimit:     limit,
+			RateRemaining: remaining,
+		}
+	}
+
+	switch recordType {
+	case "CNAME":
+		// The address records must go before a CNAME can be created
+		if err := removeAddress(ctx, record); err != nil {
+			return dnsFailure(err, "address DNS record")
+		}
+		record.CNAME = value
+		if err := publishCNAME(ctx, record); err != nil {
+			// Remember the address is gone even though the CNAME failed
+			record.CNAME = previous
+			if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+				fmt.Printf("Warning: Failed to update database record: %v\n", err)
+			}
+			return dnsFailure(err, "CNAME record")
+		}
+	case "TXT":
+		if err := route53.UpsertRecord(ctx, record.ZoneID, hostname, types.RRTypeTxt, value, EffectiveTTL(record)); err != nil {
+			return dnsFailure(err, "TXT record")
+		}
+		record.TXT = value
+	case "MX":
+		record.MX, record.BackMX = value, backMX
+		if err := publishMX(ctx, record); err != nil {
+			return dnsFailure(err, "MX record")
+		}
+	}
+
+	if err := s.store.UpdateDDNSRecord(ctx, record); err != nil {
+		// Log error but don't fail - Route 53 was already updated
+		fmt.Printf("Warning: Failed to update database record: %v\n", err)
+	}
+
+	writeUpdateLog(ctx, s.store, hostname, &database.UpdateLog{
+		RecordType: recordType,
+		PreviousIP: previous,
+		NewIP:      value,
+		SourceIP:   req.SourceIP,
+		UserAgent:  req.UserAgent,
+		Wildcard:   record.Wildcard,
+		Status:     "success",
+	})
+
+	return &UpdateResult{
+		Success:       true,
+		Code:          ResponseGood,
+		Message:       "Update successful",
+		IP:            value,
+		TTL:           EffectiveTTL(record),
+		RateLimit:     limit,
+		RateRemaining: remaining,
+	}
+}
@@ -2,16 +2,28 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
+	"dynamic-route-53-dns/internal/database"
 	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
 )
 
 // ZoneService handles zone-related operations
-type ZoneService struct{}
+type ZoneService struct {
+	store database.Store
+}
 
 // NewZoneService creates a new zone service
 func NewZoneService() *ZoneService {
-	return &ZoneService{}
+	return &ZoneService{store: database.GetStore()}
 }
 
 // ListZones returns all hosted zones
@@ -19,6 +31,45 @@ func (s *ZoneService) ListZones(ctx context.Context) ([]route53.Zone, error) {
 	return route53.ListZones(ctx)
 }
 
+// ZoneSummary is a hosted zone with the DDNS records managed in it
+type ZoneSummary struct {
+	route53.Zone
+	DDNSRecords  int // DDNS records in the zone
+	DDNSDisabled int // of which are disabled
+}
+
+// ListZoneSummaries returns all hosted zones with their DDNS record counts
+func (s *ZoneService) ListZoneSummaries(ctx context.Context) ([]ZoneSummary, error) {
+	zones, err := route53.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.store.ListDDNSRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total := make(map[string]int)
+	disabled := make(map[string]int)
+	for _, record := range records {
+		total[record.ZoneID]++
+		if !record.Enabled {
+			disabled[record.ZoneID]++
+		}
+	}
+
+	summaries := make([]ZoneSummary, 0, len(zones))
+	for _, zone := range zones {
+		summaries = append(summaries, ZoneSummary{
+			Zone:         zone,
+			DDNSRecords:  total[zone.ID],
+			DDNSDisabled: disabled[zone.ID],
+		})
+	}
+	return summaries, nil
+}
+
 // GetZone returns a specific zone
 func (s *ZoneService) GetZone(ctx context.Context, zoneID string) (*route53.Zone, error) {
 	return route53.GetZone(ctx, zoneID)
@@ -28,3 +79,203 @@ func (s *ZoneService) GetZone(ctx context.Context, zoneID string) (*route53.Zone
 func (s *ZoneService) GetZoneRecords(ctx context.Context, zoneID string) ([]route53.Record, error) {
 	return route53.ListRecords(ctx, zoneID)
 }
+
+// RecordsETag returns a strong ETag over a zone's records. Any change to a
+// record's name, type, TTL, values or alias target yields a different tag;
+// extra distinguishes other per-response inputs such as the viewer's session.
+func RecordsETag(records []route53.Record, extra ...string) string {
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(records); err != nil {
+		return ""
+	}
+	for _, e := range extra {
+		h.Write([]byte(e))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// Bounds for fetching every zone's records at once. Concurrency stays low
+// because Route 53 throttles API calls per account.
+const (
+	ZoneFetchConcurrency = 4
+	ZoneFetchTimeout     = 20 * time.Second
+)
+
+// ZoneRecords holds the records of one zone, or the error that prevented
+// fetching them
+type ZoneRecords struct {
+	Zone    route53.Zone
+	Records []route53.Record
+	Err     error
+}
+
+// GetAllZoneRecords fetches the records of every hosted zone concurrently.
+// Per-zone failures are reported in the results rather than failing the call;
+// only listing the zones themselves can return an error.
+func (s *ZoneService) GetAllZoneRecords(ctx context.Context) ([]ZoneRecords, error) {
+	zones, err := route53.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ZoneFetchTimeout)
+	defer cancel()
+
+	results := make([]ZoneRecords, len(zones))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < ZoneFetchConcurrency && w < len(zones); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Records, results[i].Err = route53.ListRecords(ctx, zones[i].ID)
+			}
+		}()
+	}
+
+	for i, zone := range zones {
+		results[i].Zone = zone
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// AliasConfig represents an alias record to create or delete
+type AliasConfig struct {
+	Name                 string
+	RecordType           string // A or AAAA
+	TargetDNSName        string
+	TargetZoneID         string
+	EvaluateTargetHealth bool
+}
+
+// validateAlias checks an alias belongs to the zone and has a usable target
+func (s *ZoneService) validateAlias(ctx context.Context, zoneID string, alias *AliasConfig) (*route53.Zone, error) {
+	zone, err := route53.GetZone(ctx, zoneID)
+	if err != nil || zone == nil {
+		return nil, ErrZoneNotFound
+	}
+
+	name := strings.TrimSuffix(alias.Name, ".")
+	if name != zone.Name && !strings.HasSuffix(name, "."+zone.Name) {
+		return nil, validationErrorf("%s is not within zone %s", alias.Name, zone.Name)
+	}
+	if alias.RecordType != string(types.RRTypeA) && alias.RecordType != string(types.RRTypeAaaa) {
+		return nil, validationErrorf("alias record type must be A or AAAA")
+	}
+	if !ValidateHostname(strings.TrimSuffix(alias.TargetDNSName, ".")) || alias.TargetZoneID == "" {
+		return nil, validationErrorf("alias target DNS name and hosted zone ID are required")
+	}
+
+	return zone, nil
+}
+
+// UpsertAlias creates or updates an alias record in a zone
+func (s *ZoneService) UpsertAlias(ctx context.Context, zoneID string, alias *AliasConfig) error {
+	if _, err := s.validateAlias(ctx, zoneID, alias); err != nil {
+		return err
+	}
+	return route53.UpsertAliasRecord(ctx, zoneID, alias.Name, types.RRType(alias.RecordType), alias.TargetDNSName, alias.TargetZoneID, alias.EvaluateTargetHealth)
+}
+
+// DeleteAlias deletes an alias record from a zone
+func (s *ZoneService) DeleteAlias(ctx context.Context, zoneID string, alias *AliasConfig) error {
+	if _, err := s.validateAlias(ctx, zoneID, alias); err != nil {
+		return err
+	}
+	return route53.DeleteAliasRecord(ctx, zoneID, alias.Name, types.RRType(alias.RecordType), alias.TargetDNSName, alias.TargetZoneID, alias.EvaluateTargetHealth)
+}
+
+// RecordConfig represents a plain (non-alias) record set to create, edit, or delete
+type RecordConfig struct {
+	Name       string
+	RecordType string // A, AAAA, CNAME, or TXT
+	TTL        int64
+	Values     []string
+}
+
+// EditableRecordTypes lists the record types that can be edited from the zone page
+var EditableRecordTypes = []string{"A", "AAAA", "CNAME", "TXT"}
+
+// validateRecord checks a record belongs to the zone, is not managed by DDNS,
+// and has values valid for its type. TXT values are quoted as Route 53 requires.
+func (s *ZoneService) validateRecord(ctx context.Context, zoneID string, record *RecordConfig) error {
+	zone, err := route53.GetZone(ctx, zoneID)
+	if err != nil || zone == nil {
+		return ErrZoneNotFound
+	}
+
+	record.Name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(record.Name), "."))
+	if record.Name != zone.Name && !strings.HasSuffix(record.Name, "."+zone.Name) {
+		return validationErrorf("%s is not within zone %s", record.Name, zone.Name)
+	}
+	if len(record.Values) == 0 {
+		return validationErrorf("at least one value is required")
+	}
+	if record.TTL <= 0 {
+		record.TTL = 300
+	}
+
+	switch record.RecordType {
+	case "A", "AAAA":
+		for _, value := range record.Values {
+			ip := net.ParseIP(value)
+			if ip == nil || (ip.To4() != nil) != (record.RecordType == "A") {
+				return validationErrorf("%s is not a valid %s value", value, record.RecordType)
+			}
+		}
+		ddns, err := s.store.GetDDNSRecord(ctx, record.Name)
+		if err != nil {
+			return err
+		}
+		if ddns != nil {
+			return validationErrorf("%s is managed by DDNS; edit it from the DDNS page", record.Name)
+		}
+	case "CNAME":
+		if len(record.Values) != 1 || !ValidateHostname(strings.TrimSuffix(record.Values[0], ".")) {
+			return validationErrorf("a CNAME needs exactly one valid target hostname")
+		}
+		if record.Name == zone.Name {
+			return validationErrorf("a CNAME cannot be created at the zone apex")
+		}
+	case "TXT":
+		for i, value := range record.Values {
+			if !strings.HasPrefix(value, `"`) {
+				record.Values[i] = route53.FormatValue(types.RRTypeTxt, value)
+			}
+		}
+	default:
+		return validationErrorf("record type must be one of %s", strings.Join(EditableRecordTypes, ", "))
+	}
+
+	return nil
+}
+
+// UpsertRecord creates or replaces a record set in a zone
+func (s *ZoneService) UpsertRecord(ctx context.Context, zoneID string, record *RecordConfig) error {
+	if err := s.validateRecord(ctx, zoneID, record); err != nil {
+		return err
+	}
+	if err := route53.UpsertRecordValues(ctx, zoneID, record.Name, types.RRType(record.RecordType), record.Values, record.TTL); err != nil {
+		return err
+	}
+	route53.InvalidateCache()
+	return nil
+}
+
+// DeleteRecord deletes a record set from a zone. Values and TTL must match
+// the stored record set exactly.
+func (s *ZoneService) DeleteRecord(ctx context.Context, zoneID string, record *RecordConfig) error {
+	if err := s.validateRecord(ctx, zoneID, record); err != nil {
+		return err
+	}
+	if err := route53.DeleteRecordValues(ctx, zoneID, record.Name, types.RRType(record.RecordType), record.Values, record.TTL); err != nil {
+		return err
+	}
+	route53.InvalidateCache()
+	return nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"dynamic-route-53-dns/internal/route53"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsroute53 "github.com/aws/aws-sdk-go-v2/service/route53"
+)
+
+// fakeZoneAPI serves two hosted zones, failing the record listing of Z2
+type fakeZoneAPI struct{}
+
+func (fakeZoneAPI) Do(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, ""
+	switch path := req.URL.Path; {
+	case strings.HasSuffix(path, "/hostedzone"):
+		body = `<?xml version="1.0" encoding="UTF-8"?>
+<ListHostedZonesResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <HostedZones>
+    <HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name><CallerReference>1</CallerReference><ResourceRecordSetCount>1</ResourceRecordSetCount></HostedZone>
+    <HostedZone><Id>/hostedzone/Z2</Id><Name>example.net.</Name><CallerReference>2</CallerReference><ResourceRecordSetCount>1</ResourceRecordSetCount></HostedZone>
+  </HostedZones>
+  <IsTruncated>false</IsTruncated>
+  <MaxItems>100</MaxItems>
+</ListHostedZonesResponse>`
+	case strings.HasSuffix(path, "/dnssec"):
+		body = `<?xml version="1.0" encoding="UTF-8"?>
+<GetDNSSECResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <Status><ServeSignature>NOT_SIGNING</ServeSignature></Status>
+  <KeySigningKeys></KeySigningKeys>
+</GetDNSSECResponse>`
+	case strings.HasSuffix(path, "/hostedzone/Z1/rrset"):
+		body = `<?xml version="1.0" encoding="UTF-8"?>
+<ListResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <ResourceRecordSets>
+    <ResourceRecordSet>
+      <Name>home.example.com.</Name>
+      <Type>A</Type>
+      <TTL>60</TTL>
+      <ResourceRecords><ResourceRecord><Value>198.51.100.1</Value></ResourceRecord></ResourceRecords>
+    </ResourceRecordSet>
+  </ResourceRecordSets>
+  <IsTruncated>false</IsTruncated>
+  <MaxItems>300</MaxItems>
+</ListResourceRecordSetsResponse>`
+	default:
+		status = http.StatusBadRequest
+		body = `<?xml version="1.0" encoding="UTF-8"?>
+<ErrorResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <Error><Type>Sender</Type><Code>NoSuchHostedZone</Code><Message>No hosted zone found with ID: Z2</Message></Error>
+  <RequestId>fake</RequestId>
+</ErrorResponse>`
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestGetAllZoneRecordsCollectsPerZoneErrors(t *testing.T) {
+	previous := route53.GetClient()
+	route53.SetClient(awsroute53.New(awsroute53.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  fakeZoneAPI{},
+	}))
+	route53.InvalidateCache()
+	t.Cleanup(func() {
+		route53.SetClient(previous)
+		route53.InvalidateCache()
+	})
+
+	results, err := NewZoneService().GetAllZoneRecords(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllZoneRecords: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d zones, want 2", len(results))
+	}
+
+	ok, failed := results[0], results[1]
+	if ok.Zone.ID != "Z1" || failed.Zone.ID != "Z2" {
+		t.Fatalf("zones = %s, %s; want results in zone order", ok.Zone.ID, failed.Zone.ID)
+	}
+	if ok.Err != nil || len(ok.Records) != 1 || ok.Records[0].Name != "home.example.com" {
+		t.Errorf("Z1 = %+v, %v; want its one record", ok.Records, ok.Err)
+	}
+	if failed.Err == nil || failed.Records != nil {
+		t.Errorf("Z2 = %+v, %v; want the listing error and no records", failed.Records, failed.Err)
+	}
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TraceHeader carries the Lambda invocation's trace header from the Lambda
+// handler into the Fiber app, which otherwise never sees the invocation context
+const TraceHeader = "X-Lambda-Trace-Id"
+
+// Enabled reports whether XRAY_ENABLED turns on AWS X-Ray tracing
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("XRAY_ENABLED"))
+	return enabled
+}
+
+// InstrumentConfig adds X-Ray subsegments for every AWS SDK call made by
+// clients built from cfg
+func InstrumentConfig(cfg *aws.Config) {
+	if Enabled() {
+		awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+	}
+}
+
+// LambdaTraceID returns the trace header of the Lambda invocation in ctx
+func LambdaTraceID(ctx context.Context) string {
+	header, _ := ctx.Value(xray.LambdaTraceHeaderKey).(string)
+	return header
+}
+
+// Middleware makes the invocation's trace context the request's user context
+// so SDK calls made with c.UserContext() join the Lambda trace
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Enabled() {
+			return c.Next()
+		}
+		if header := c.Get(TraceHeader); header != "" {
+			c.SetUserContext(context.WithValue(c.UserContext(), xray.LambdaTraceHeaderKey, header))
+		}
+		return c.Next()
+	}
+}
+
+// Capture runs fn inside an X-Ray subsegment named name when tracing is enabled
+func Capture(ctx context.Context, name string, fn func() error) error {
+	if !Enabled() {
+		return fn()
+	}
+	return xray.Capture(ctx, name, func(context.Context) error {
+		return fn()
+	})
+}
@@ -1,4 +1,4 @@
-package main
+package web
 
 import (
 	"bytes"
@@ -8,12 +8,15 @@ import (
 	"io/fs"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // HTMLEngine is a custom template engine for Fiber
 type HTMLEngine struct {
 	templates *template.Template
 	fs        fs.FS
+	reload    bool // re-read templates on every render (development)
+	mu        sync.RWMutex
 }
 
 // NewHTMLEngine creates a new HTML template engine
@@ -27,17 +30,10 @@ func NewHTMLEngine(fsys fs.FS) *HTMLEngine {
 
 // load loads all templates from the filesystem
 func (e *HTMLEngine) load() {
-	e.templates = template.New("")
+	templates := template.New("")
 
 	// Define template functions
-	e.templates.Funcs(template.FuncMap{
-		"safeHTML": func(s string) template.HTML {
-			return template.HTML(s)
-		},
-		"formatTime": func(t interface{}) string {
-			return fmt.Sprintf("%v", t)
-		},
-	})
+	templates.Funcs(templateFuncs())
 
 	// Walk through all template files
 	fs.WalkDir(e.fs, ".", func(path string, d fs.DirEntry, err error) error {
@@ -61,13 +57,17 @@ func (e *HTMLEngine) load() {
 		name := strings.TrimSuffix(path, ".html")
 		name = strings.ReplaceAll(name, "\\", "/") // Normalize path separators
 
-		_, err = e.templates.New(name).Parse(string(content))
+		_, err = templates.New(name).Parse(string(content))
 		if err != nil {
 			fmt.Printf("Error parsing template %s: %v\n", name, err)
 		}
 
 		return nil
 	})
+
+	e.mu.Lock()
+	e.templates = templates
+	e.mu.Unlock()
 }
 
 // Render renders a template
@@ -75,8 +75,17 @@ func (e *HTMLEngine) Render(w io.Writer, name string, binding interface{}, layou
 	// Normalize the name
 	name = strings.ReplaceAll(name, "\\", "/")
 
+	// Pick up template edits from disk in development
+	if e.reload {
+		e.load()
+	}
+
+	e.mu.RLock()
+	templates := e.templates
+	e.mu.RUnlock()
+
 	// Get the template
-	tmpl := e.templates.Lookup(name)
+	tmpl := templates.Lookup(name)
 	if tmpl == nil {
 		return fmt.Errorf("template %s not found", name)
 	}
@@ -84,7 +93,7 @@ func (e *HTMLEngine) Render(w io.Writer, name string, binding interface{}, layou
 	// Check if we have a layout
 	if len(layout) > 0 && layout[0] != "" {
 		layoutName := strings.ReplaceAll(layout[0], "\\", "/")
-		layoutTmpl := e.templates.Lookup(layoutName)
+		layoutTmpl := templates.Lookup(layoutName)
 		if layoutTmpl != nil {
 			// Render the content template first
 			var contentBuf bytes.Buffer
@@ -110,7 +119,7 @@ func (e *HTMLEngine) Render(w io.Writer, name string, binding interface{}, layou
 	return tmpl.Execute(w, binding)
 }
 
-// Load reloads templates (for development)
+// Load reloads templates. When the engine reads from disk this picks up edits.
 func (e *HTMLEngine) Load() error {
 	e.load()
 	return nil
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayout is used by formatTime when no layout is given
+const defaultTimeLayout = "2006-01-02 15:04:05 UTC"
+
+// templateFuncs returns the helper functions available to all templates
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"safeHTML": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+		"formatTime": formatTime,
+		"timeAgo":    timeAgo,
+		"formatIP":   formatIP,
+		"formatTags": formatTags,
+	}
+}
+
+// toTime converts a time.Time or a Unix-epoch integer into a time.Time
+func toTime(t interface{}) (time.Time, bool) {
+	switch v := t.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, !v.IsZero()
+	case int64:
+		return time.Unix(v, 0), v != 0
+	case int:
+		return time.Unix(int64(v), 0), v != 0
+	default:
+		return time.Time{}, false
+	}
+}
+
+// formatTime formats a time in UTC with an optional layout, or "Never" when unset
+func formatTime(t interface{}, layout ...string) string {
+	tm, ok := toTime(t)
+	if !ok {
+		return "Never"
+	}
+
+	l := defaultTimeLayout
+	if len(layout) > 0 && layout[0] != "" {
+		l = layout[0]
+	}
+	return tm.UTC().Format(l)
+}
+
+// timeAgo formats a time relative to now, e.g. "5 minutes ago" or "in 2 hours"
+func timeAgo(t interface{}) string {
+	tm, ok := toTime(t)
+	if !ok {
+		return "Never"
+	}
+
+	d := time.Since(tm)
+	suffix := "ago"
+	if d < 0 {
+		d = -d
+		suffix = "from now"
+	}
+
+	var amount int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		amount, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		amount, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		amount, unit = int(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		amount, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		amount, unit = int(d/(365*24*time.Hour)), "year"
+	}
+
+	if amount != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s %s", amount, unit, suffix)
+}
+
+// formatIP returns an IP address in canonical form, or "-" when empty
+func formatIP(ip string) string {
+	if ip == "" {
+		return "-"
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+// formatTags renders tags as sorted "key=value" pairs separated by commas
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
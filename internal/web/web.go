@@ -0,0 +1,29 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+// NewEngine creates an HTML engine backed by the embedded templates, so the
+// binary is self-contained. Setting TEMPLATES_DIR serves templates from disk
+// instead and reloads them on every render for development.
+func NewEngine() (*HTMLEngine, error) {
+	if dir := os.Getenv("TEMPLATES_DIR"); dir != "" {
+		engine := NewHTMLEngine(os.DirFS(dir))
+		engine.reload = true
+		return engine, nil
+	}
+
+	// Get templates subdirectory
+	templatesSubFS, err := fs.Sub(templatesFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHTMLEngine(templatesSubFS), nil
+}